@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//agents lists the recorded user agents that the user agent rules cannot describe, to seed new rules
+func agents(args []string) {
+	flags := flag.NewFlagSet("agents", flag.ExitOnError)
+	dataDir := flags.String("data", ".", "The directory holding browser-data.json")
+	asJSON := flags.Bool("json", false, "Print the agents as JSON")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+
+	data, err := bta.GetRawData(*dataDir)
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	unknown := bta.UnrecognisedAgents(data)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(unknown); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Records\tUnknown\tAgent")
+	for _, a := range unknown {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", a.Records, a.Part, a.Agent)
+	}
+	w.Flush()
+	fmt.Printf("\n%d unrecognised agents in %d records\n", len(unknown), len(data))
+}
+
+//agentRulesFlag adds a -rules flag to flags, returning a function that applies the rules file, if any, once the flags are parsed
+func agentRulesFlag(flags *flag.FlagSet) func() {
+	file := flags.String("rules", "", "A JSON file of user agent rules to use instead of the built in ones")
+	return func() {
+		if *file == "" {
+			return
+		}
+		rules, err := bta.LoadAgentRules(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		bta.SetAgentRules(rules)
+	}
+}
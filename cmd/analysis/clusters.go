@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//clusters prints the distinct TLS stacks observed: the records grouped by canonical fingerprint, with their user agents and browser versions
+func clusters(args []string) {
+	flags := flag.NewFlagSet("clusters", flag.ExitOnError)
+	dataDir := flags.String("data", ".", "The directory holding browser-data.json")
+	unordered := flags.Bool("unordered", false, "Group records offering the same features in a different order")
+	dedup := flags.Bool("dedup", false, "Count the repeated visits of a user agent with the same fingerprint once")
+	asJSON := flags.Bool("json", false, "Print the clusters as JSON")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+
+	data, err := bta.GetEnrichedData(*dataDir)
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	records := len(data)
+	if *dedup {
+		data = bta.Deduplicate(data)
+	}
+
+	clusters := bta.ClusterFingerprints(data, !*unordered)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(clusters); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Fingerprint\tRecords\tAgents\tBrowsers")
+	for _, c := range clusters {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", c.Fingerprint, c.Records, len(c.Agents), c.Versions)
+	}
+	w.Flush()
+	fmt.Printf("\n%d distinct fingerprints in %d records", len(clusters), len(data))
+	if *dedup {
+		fmt.Printf(", %d before removing repeated visits", records)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//diff compares two snapshots of the enriched data, such as data/enriched-browser-data.json and one of its dated backups
+func diff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	minShift := flags.Float64("min-shift", 0.01, "Only report the features whose share of records changed by at least this fraction, e.g. 0.01 for a percentage point")
+	asJSON := flags.Bool("json", false, "Print the differences as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: analysis diff [flags] older.json newer.json")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	before, err := bta.LoadCapabilities(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	after, err := bta.LoadCapabilities(flags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	d := bta.DiffCapabilities(before, after)
+	shifts := []bta.FeatureShift{}
+	for _, s := range d.Shifts {
+		if math.Abs(s.Change()) >= *minShift {
+			shifts = append(shifts, s)
+		}
+	}
+	d.Shifts = shifts
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	fmt.Printf("Comparing %d records of %s with %d records of %s\n", d.BeforeRecords, d.Before.Format("2006-01-02 15:04"), d.AfterRecords,
+		d.After.Format("2006-01-02 15:04"))
+	if len(d.NewClients) > 0 {
+		fmt.Println("\nNew clients:")
+		printCounts(d.NewClients)
+	}
+	if len(d.RemovedClients) > 0 {
+		fmt.Println("\nRemoved clients:")
+		printCounts(d.RemovedClients)
+	}
+	if len(d.Changed) > 0 {
+		fmt.Println("\nCapabilities changed for the same user agent:")
+		for _, c := range d.Changed {
+			fmt.Printf("  %s\n", c.Agent)
+			if len(c.Added) == 0 && len(c.Removed) == 0 {
+				fmt.Println("    the same features, in a different order or combination")
+			}
+			for _, f := range c.Added {
+				fmt.Printf("    + %s\n", f)
+			}
+			for _, f := range c.Removed {
+				fmt.Printf("    - %s\n", f)
+			}
+		}
+	}
+	if len(d.Shifts) > 0 {
+		fmt.Println("\nShare of records offering:")
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, s := range d.Shifts {
+			fmt.Fprintf(w, "  %s\t%s\t%.1f%%\t-> %.1f%%\t(%+.1f points)\n", s.Feature, s.Value, 100*s.Before, 100*s.After, 100*s.Change())
+		}
+		w.Flush()
+	}
+}
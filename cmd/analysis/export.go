@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//fileExport streams the enriched data to a file
+type fileExport struct {
+	file     *os.File
+	exporter bta.CapabilityExporter
+}
+
+//openExport starts an export to the file with the exporter, or returns nil if the file is empty
+func openExport(file string, newExporter func(io.Writer) (bta.CapabilityExporter, error)) *fileExport {
+	if file == "" {
+		return nil
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	exporter, err := newExporter(out)
+	if err != nil {
+		out.Close()
+		log.Fatal(err)
+	}
+	return &fileExport{file: out, exporter: exporter}
+}
+
+func (e *fileExport) write(c bta.TLSClientCapability) error {
+	if e == nil {
+		return nil
+	}
+	return e.exporter.Write(c)
+}
+
+func (e *fileExport) close() {
+	if e == nil {
+		return
+	}
+	if err := e.exporter.Close(); err != nil {
+		e.file.Close()
+		log.Fatal(err)
+	}
+	if err := e.file.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
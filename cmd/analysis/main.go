@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
 	"path"
@@ -13,39 +15,101 @@ import (
 )
 
 func main() {
-	data := bta.GetEnrichedData(".")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "simulate":
+			simulate(os.Args[2:])
+			return
+		case "probe":
+			probe(os.Args[2:])
+			return
+		case "recommend":
+			recommend(os.Args[2:])
+			return
+		case "report":
+			report(os.Args[2:])
+			return
+		case "agents":
+			agents(os.Args[2:])
+			return
+		case "clusters":
+			clusters(os.Args[2:])
+			return
+		case "timeline":
+			timeline(os.Args[2:])
+			return
+		case "diff":
+			diff(os.Args[2:])
+			return
+		case "query":
+			query(os.Args[2:])
+			return
+		}
+	}
+	enrich()
+}
+
+//enrich writes the enriched data to data/enriched-browser-data.json, backing up any existing file, and optionally exports it as CSV and Parquet
+func enrich() {
+	limit := flag.Int("limit", 0, "The maximum number of records to analyse, 0 for all")
+	wideCSV := flag.String("csv", "", "Also export the enriched data to this file as CSV, one row per record")
+	longCSV := flag.String("long-csv", "", "Also export the enriched data to this file as CSV, one row per record and feature")
+	parquet := flag.String("parquet", "", "Also export the enriched data to this file as Parquet, one row per record and feature")
+	loadRules := agentRulesFlag(flag.CommandLine)
+	flag.Parse()
+	loadRules()
+
 	dataPath := path.Join("data", "enriched-browser-data.json")
-	if _, err := os.Stat(dataPath); !os.IsNotExist(err) {
+	if in, err := os.Open(dataPath); err == nil {
 		//there is an existing data, back it up.
-		if d, err := ioutil.ReadFile(dataPath); err == nil {
-			caps := bta.TLSCapabilities{}
-			if err := json.Unmarshal(d, &caps); err == nil {
-				if err := os.Rename(dataPath, path.Join("data", fmt.Sprintf("%s-enriched-browser-data.json", caps.Timestamp.Format("20060102")))); err != nil {
-					log.Fatal(err)
-					return
-				}
-			} else {
-				log.Fatal(err)
-				return
-			}
+		caps := struct{ Timestamp time.Time }{}
+		err := json.NewDecoder(in).Decode(&caps)
+		in.Close()
+		if err != nil {
+			log.Fatal(err)
+			return
+		}
+		if err := os.Rename(dataPath, path.Join("data", fmt.Sprintf("%s-enriched-browser-data.json", caps.Timestamp.Format("20060102")))); err != nil {
+			log.Fatal(err)
+			return
 		}
 	}
 
-	out, err := os.OpenFile(dataPath, os.O_CREATE|os.O_WRONLY, 0644)
+	out, err := os.OpenFile(dataPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		log.Fatal(err)
 		return
 	}
 	defer out.Close()
 
-	caps := bta.TLSCapabilities{
-		Timestamp:    time.Now(),
-		Capabilities: data,
+	writer, err := bta.NewCapabilitiesWriter(out, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	exports := []*fileExport{
+		openExport(*wideCSV, func(w io.Writer) (bta.CapabilityExporter, error) { return bta.NewWideCSVWriter(w) }),
+		openExport(*longCSV, func(w io.Writer) (bta.CapabilityExporter, error) { return bta.NewLongCSVWriter(w) }),
+		openExport(*parquet, func(w io.Writer) (bta.CapabilityExporter, error) { return bta.NewLongParquetWriter(w) }),
+	}
+	err = bta.IterateEnrichedData(context.Background(), ".", bta.ReadOptions{Limit: *limit, SkipMalformed: true}, func(c bta.TLSClientCapability) error {
+		for _, e := range exports {
+			if err := e.write(c); err != nil {
+				return err
+			}
+		}
+		return writer.Write(c)
+	})
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
 	}
-	if js, err := json.MarshalIndent(caps, "", " "); err == nil {
-		out.Write(js)
-		out.Sync()
-	} else {
+	if err := writer.Close(); err != nil {
 		log.Fatal(err)
 	}
+	out.Sync()
+
+	for _, e := range exports {
+		e.close()
+	}
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//fingerprintGroup is the records sharing a TLS fingerprint
+type fingerprintGroup struct {
+	fingerprint string
+	client      bta.TLSClientCapability //the first record, replayed for the group
+	records     int
+	browsers    map[string]bool
+}
+
+//probe replays every distinct fingerprint in the data against a live server and prints a compatibility report
+func probe(args []string) {
+	flags := flag.NewFlagSet("probe", flag.ExitOnError)
+	target := flags.String("target", "", "The host:port of the server to probe")
+	serverName := flags.String("sni", "", "The SNI to send in place of the recorded one, defaults to the target host unless it is an IP address")
+	dataDir := flags.String("data", ".", "The directory holding browser-data.json")
+	limit := flags.Int("limit", 0, "The maximum number of records to read, 0 for all")
+	timeout := flags.Duration("timeout", 10*time.Second, "The timeout of each handshake")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+	if *target == "" {
+		log.Fatal("Expects a -target host:port")
+	}
+	host, _, err := net.SplitHostPort(*target)
+	if err != nil {
+		log.Fatalf("Expects a -target host:port: %s", err)
+	}
+	if *serverName == "" && net.ParseIP(host) == nil {
+		//SNI is for host names only (RFC 6066), an IP address target keeps the recorded one
+		*serverName = host
+	}
+
+	groups := map[string]*fingerprintGroup{}
+	order := []string{}
+	err = bta.IterateEnrichedData(context.Background(), *dataDir, bta.ReadOptions{Limit: *limit, SkipMalformed: true}, func(c bta.TLSClientCapability) error {
+		fp := fingerprintOf(c)
+		g, ok := groups[fp]
+		if !ok {
+			g = &fingerprintGroup{fingerprint: fp, client: c, browsers: map[string]bool{}}
+			groups[fp] = g
+			order = append(order, fp)
+		}
+		g.records++
+		g.browsers[strings.TrimSpace(fmt.Sprintf("%s %s", c.ClientDescription.Browser, c.ClientDescription.BrowserVersion))] = true
+		return nil
+	})
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Fingerprint\tRecords\tBrowsers\tResult")
+	compatible, records, connected, failed := 0, 0, 0, 0
+	for _, fp := range order {
+		g := groups[fp]
+		records += g.records
+		r, err := bta.Probe(context.Background(), *target, g.client, bta.ProbeOptions{ServerName: *serverName, Timeout: *timeout})
+		if err != nil {
+			fmt.Fprintf(w, "%s\t%d\t%s\tNot probed: %s\n", g.fingerprint, g.records, browserList(g.browsers), err)
+			failed++
+			continue
+		}
+		if r.OK() {
+			compatible++
+			connected += g.records
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", g.fingerprint, g.records, browserList(g.browsers), r)
+	}
+	w.Flush()
+	fmt.Printf("\n%d of %d fingerprints (%d of %d records) can connect to %s\n", compatible, len(order), connected, records, *target)
+	if failed > 0 {
+		fmt.Printf("%d fingerprints could not be probed\n", failed)
+	}
+}
+
+//fingerprintOf is the JA3 hash of a record captured with its ClientHello, otherwise an MD5 hash of its capability
+func fingerprintOf(c bta.TLSClientCapability) string {
+	if c.Fingerprints.JA3Hash != "" {
+		return c.Fingerprints.JA3Hash
+	}
+	h := c.Capability
+	return fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%v,%v,%v,%v,%v,%v",
+		h.SupportedVersions, h.CipherSuites, h.SupportedCurves, h.SupportedPoints, h.SignatureSchemes, h.SupportedProtos))))
+}
+
+func browserList(browsers map[string]bool) string {
+	list := []string{}
+	for b := range browsers {
+		list = append(list, b)
+	}
+	sort.Strings(list)
+	if len(list) > 3 {
+		return fmt.Sprintf("%s and %d more", strings.Join(list[:3], ", "), len(list)-3)
+	}
+	return strings.Join(list, ", ")
+}
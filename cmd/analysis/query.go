@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//query prints the stored records selected by browser, operating system, version, capture time or fingerprint, one JSON record per line
+func query(args []string) {
+	flags := flag.NewFlagSet("query", flag.ExitOnError)
+	dataDir := flags.String("data", ".", "The directory holding the store")
+	storeKind := flags.String("store", bta.JSONLinesStoreKind, fmt.Sprintf("The store to read: %s (browser-data.json) or %s (browser-data.db)", bta.JSONLinesStoreKind, bta.BoltStoreKind))
+	browser := flags.String("browser", "", "Only records of this browser, e.g. Firefox")
+	osName := flags.String("os", "", "Only records of this operating system, e.g. Windows 10")
+	versions := flags.String("versions", "", "Only records of these browsers or operating systems by version, e.g. Chrome >= 70 < 76")
+	since := flags.String("since", "", "Only records captured at or after this time, e.g. 2019-06-01 or 2019-06-01T12:00:00Z")
+	until := flags.String("until", "", "Only records captured before this time")
+	fingerprint := flags.String("fingerprint", "", "Only records with this JA3, JA3 hash, JA4 or JA4H fingerprint. Trailing JA4 and JA4H sections may be left out")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+
+	q := bta.Query{Browser: *browser, OS: *osName, Since: parseTime(*since), Until: parseTime(*until), Fingerprint: *fingerprint}
+	if *versions != "" {
+		r, err := bta.ParseVersionRange(*versions)
+		if err != nil {
+			log.Fatal(err)
+		}
+		q.Versions = &r
+	}
+
+	store, err := bta.OpenStore(*storeKind, *dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+	records, err := store.Query(context.Background(), q)
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+//parseTime reads a time flag as an RFC 3339 time or a date, the zero time if empty
+func parseTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	log.Fatalf("Expects a time such as 2019-06-01 or 2019-06-01T12:00:00Z: %s", value)
+	return time.Time{}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//recommend prints the most restrictive server policy that the recorded clients can connect to, and the clients forcing each concession
+func recommend(args []string) {
+	flags := flag.NewFlagSet("recommend", flag.ExitOnError)
+	coverage := flags.Float64("coverage", 1, "The fraction of records that must be able to connect, e.g. 0.99")
+	browser := flags.String("browser", "", "Only serve these browsers, a comma separated list of browsers or version ranges e.g. Chrome >= 70,Firefox")
+	keyType := flags.String("key-type", bta.KeyTypeRSA, fmt.Sprintf("The certificate key type: %s, %s or %s", bta.KeyTypeRSA, bta.KeyTypeECDSA, bta.KeyTypeEd25519))
+	dataDir := flags.String("data", ".", "The directory holding browser-data.json")
+	output := flags.String("output", "", "Write the recommended policy to this file, for use with simulate -policy")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+
+	data, err := bta.GetEnrichedData(*dataDir)
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	opts := bta.RecommendOptions{Coverage: *coverage, CertificateKeyType: *keyType}
+	if *browser != "" {
+		browsers, err := bta.ParseVersionRange(*browser)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Select = func(c bta.TLSClientCapability) bool { return browsers.Matches(c.ClientDescription) }
+	}
+	rec := bta.Recommend(data, opts)
+
+	js, err := json.MarshalIndent(rec.Policy, "", " ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output != "" {
+		if err := ioutil.WriteFile(*output, js, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("%s\n\n%d of %d records can connect\n", js, rec.Covered, rec.Records)
+	for _, c := range rec.Concessions {
+		if len(c.Clients) == 0 {
+			fmt.Printf("\n%s (%s) is enabled, but every client can connect without it\n", c.Setting, c.Reason)
+			continue
+		}
+		fmt.Printf("\n%s (%s) is needed to connect by:\n", c.Setting, c.Reason)
+		printCounts(c.Clients)
+	}
+	if len(rec.Excluded) > 0 {
+		fmt.Printf("\nLeft out to meet the coverage of %g:\n", *coverage)
+		printCounts(rec.Excluded)
+	}
+	if len(rec.Unsupported) > 0 {
+		fmt.Println("\nCannot connect with any recommended setting:")
+		printCounts(rec.Unsupported)
+	}
+}
+
+func printCounts(counts []bta.ClientCount) {
+	for _, c := range counts {
+		d := c.ClientDescription
+		fmt.Printf("  %s %s on %s (%d records)\n", d.Browser, d.BrowserVersion, d.OS, c.Records)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//report renders the compatibility matrix of the recorded browsers as HTML or Markdown
+func report(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	format := flags.String("format", "html", "The report format: html or markdown")
+	output := flags.String("output", "", "The file to write the report to, defaults to the standard output")
+	dataDir := flags.String("data", ".", "The directory holding browser-data.json")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+
+	var write func(bta.Report, io.Writer) error
+	switch *format {
+	case "html":
+		write = bta.Report.WriteHTML
+	case "markdown", "md":
+		write = bta.Report.WriteMarkdown
+	default:
+		log.Fatalf("Unknown report format %s, expects html or markdown", *format)
+	}
+
+	data, err := bta.GetEnrichedData(*dataDir)
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	r := bta.BuildReport(data)
+	if *output == "" {
+		err = write(r, os.Stdout)
+	} else {
+		var file *os.File
+		if file, err = os.Create(*output); err != nil {
+			log.Fatal(err)
+		}
+		err = write(r, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//simulate prints which recorded browsers can connect to a server configured with a policy file
+func simulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	policyFile := flags.String("policy", "", "The JSON server policy file to simulate")
+	dataDir := flags.String("data", ".", "The directory holding browser-data.json")
+	limit := flags.Int("limit", 0, "The maximum number of records to simulate, 0 for all")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+	if *policyFile == "" {
+		log.Fatal("Expects a -policy file")
+	}
+
+	policy, err := bta.LoadServerPolicy(*policyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sims := []bta.ClientSimulation{}
+	err = bta.IterateEnrichedData(context.Background(), *dataDir, bta.ReadOptions{Limit: *limit, SkipMalformed: true}, func(c bta.TLSClientCapability) error {
+		sims = append(sims, bta.SimulateClients(policy, []bta.TLSClientCapability{c})...)
+		return nil
+	})
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "Browser\tVersion\tOS\tRecords\tCompatible\tResult")
+	compatible := 0
+	for _, c := range bta.SummariseCompatibility(sims) {
+		d := c.ClientDescription
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", d.Browser, d.BrowserVersion, d.OS, c.Records, c.Compatible, c.Result)
+		compatible += c.Compatible
+	}
+	w.Flush()
+	fmt.Printf("\n%d of %d records can connect\n", compatible, len(sims))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//timeline prints, for each browser and operating system family, the first and last recorded version offering each TLS feature
+func timeline(args []string) {
+	flags := flag.NewFlagSet("timeline", flag.ExitOnError)
+	dataDir := flags.String("data", ".", "The directory holding browser-data.json")
+	browser := flags.String("browser", "", "Only show these browsers, a comma separated list of browsers or version ranges e.g. Firefox,Chrome >= 49")
+	kind := flags.String("feature", "", fmt.Sprintf("Only show one kind of feature: %s, %s, %s, %s, %s or %s", bta.FeatureVersion, bta.FeatureCipherSuite,
+		bta.FeatureCurve, bta.FeaturePointFormat, bta.FeatureSignatureScheme, bta.FeatureALPN))
+	asJSON := flags.Bool("json", false, "Print the timelines as JSON")
+	loadRules := agentRulesFlag(flags)
+	flags.Parse(args)
+	loadRules()
+
+	browsers, err := bta.ParseVersionRange(*browser)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := bta.GetEnrichedData(*dataDir)
+	if _, malformed := err.(*bta.MalformedRecordsError); malformed {
+		log.Println(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	timelines := bta.BuildTimelines(bta.FilterByVersions(data, browsers))
+	if *kind != "" {
+		for i, t := range timelines {
+			features := []bta.FeatureTimeline{}
+			for _, f := range t.Features {
+				if f.Feature == *kind {
+					features = append(features, f)
+				}
+			}
+			timelines[i].Features = features
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(timelines); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, t := range timelines {
+		fmt.Printf("%s on %s: %d records of %d versions, %s to %s\n\n", t.Browser, t.OS, t.Records, len(t.Versions), t.Versions[0],
+			t.Versions[len(t.Versions)-1])
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "Kind\tFeature\tFirst\tLast\tGaps")
+		for _, f := range t.Features {
+			last := f.Last
+			if f.Dropped {
+				last += " (dropped)"
+			}
+			gaps := []string{}
+			for _, g := range f.Gaps {
+				if g.From == g.To {
+					gaps = append(gaps, g.From)
+				} else {
+					gaps = append(gaps, g.From+" to "+g.To)
+				}
+			}
+			gap := ""
+			if len(gaps) > 0 {
+				gap = "missing from " + strings.Join(gaps, ", ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.Feature, f.Value, f.First, last, gap)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	bta "github.com/adedayo/browser-tls-audit/pkg"
+)
+
+//maxHelloCapture bounds how much of a connection is buffered while waiting for a complete ClientHello
+const maxHelloCapture = 1 << 17
+
+//handshakeTimeout bounds the TLS handshake of a connection accepted by a requestListener
+const handshakeTimeout = 10 * time.Second
+
+//connections counts accepted connections, giving each a unique ID
+var connections uint64
+
+//connContextKey is the request context key of the connection carrying the request
+type connContextKey struct{}
+
+//helloListener wraps a net.Listener so that the raw ClientHello of every accepted connection is captured
+type helloListener struct {
+	net.Listener
+}
+
+func (l helloListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &helloConn{
+		Conn: c,
+		id:   bta.ConnectionID(atomic.AddUint64(&connections, 1)),
+	}, nil
+}
+
+//helloConn tees the bytes read off a connection until a complete ClientHello has been seen
+type helloConn struct {
+	net.Conn
+	id     bta.ConnectionID
+	mutex  sync.Mutex
+	buffer []byte
+	hello  *bta.ClientHello
+	done   bool
+	//deferred is set once a request on the connection has been left for the browser to retry with the critical Client Hints
+	deferred bool
+}
+
+func (c *helloConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.done && n > 0 {
+		c.buffer = append(c.buffer, b[:n]...)
+		hello, e := bta.ParseClientHello(c.buffer)
+		if e != io.ErrUnexpectedEOF || len(c.buffer) > maxHelloCapture {
+			c.hello = hello
+			c.done = true
+			c.buffer = nil
+		}
+	}
+	return n, err
+}
+
+//RemoteAddr returns the remote address of the connection, carrying the connection
+func (c *helloConn) RemoteAddr() net.Addr {
+	return connAddr{Addr: c.Conn.RemoteAddr(), conn: c}
+}
+
+//connAddr is the remote address of an accepted connection. It carries the connection so that it can be found through
+//whatever wraps it, such as the *tls.Conn that HTTP handlers see, which only exposes its underlying connection from Go 1.18
+type connAddr struct {
+	net.Addr
+	conn *helloConn
+}
+
+//ClientHello returns the captured ClientHello, or nil if none could be parsed
+func (c *helloConn) ClientHello() *bta.ClientHello {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.hello
+}
+
+//deferHints reports whether a request may be left for the browser to retry with the critical Client Hints, which is
+//allowed once per connection so that a browser not retrying is still recorded
+func (c *helloConn) deferHints() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.deferred {
+		return false
+	}
+	c.deferred = true
+	return true
+}
+
+//requestHelloConn finds the connection an HTTP request arrived on
+func requestHelloConn(req *http.Request) (*helloConn, bool) {
+	c, ok := req.Context().Value(connContextKey{}).(net.Conn)
+	if !ok {
+		return nil, false
+	}
+	addr, ok := c.RemoteAddr().(connAddr)
+	return addr.conn, ok
+}
+
+//requestConnectionID finds the ID of the connection an HTTP request arrived on
+func requestConnectionID(req *http.Request) (bta.ConnectionID, bool) {
+	c, ok := requestHelloConn(req)
+	if !ok {
+		return 0, false
+	}
+	return c.id, true
+}
+
+//requestListener completes the TLS handshake of the connections it accepts itself, so that HTTP/1.x connections can be
+//handed to the server as a requestConn recording the order of the request headers, which net/http does not preserve.
+//HTTP/2 connections are handed over as the *tls.Conn, as net/http only serves HTTP/2 on one
+type requestListener struct {
+	net.Listener
+	config *tls.Config
+	conns  chan net.Conn
+	err    chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newRequestListener(l net.Listener, config *tls.Config) *requestListener {
+	rl := &requestListener{
+		Listener: l,
+		config:   config,
+		conns:    make(chan net.Conn),
+		err:      make(chan error, 1),
+		done:     make(chan struct{}),
+	}
+	go rl.accept()
+	return rl
+}
+
+func (l *requestListener) accept() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			l.err <- err
+			return
+		}
+		go l.handshake(c)
+	}
+}
+
+func (l *requestListener) handshake(c net.Conn) {
+	tc := tls.Server(c, l.config)
+	tc.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := tc.Handshake(); err != nil {
+		tc.Close()
+		return
+	}
+	tc.SetDeadline(time.Time{})
+	var conn net.Conn = tc
+	if tc.ConnectionState().NegotiatedProtocol != "h2" {
+		conn = &requestConn{Conn: tc}
+	}
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		tc.Close()
+	}
+}
+
+func (l *requestListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case err := <-l.err:
+		return nil, err
+	}
+}
+
+func (l *requestListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+//requestConn follows the plaintext of an HTTP/1.x connection to record the order of the request headers
+type requestConn struct {
+	net.Conn
+	heads bta.RequestHeads
+}
+
+func (c *requestConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.heads.Write(b[:n])
+	return n, err
+}
+
+//requestHeaderOrder returns the header names of an HTTP/1.x request in the order they were sent, or nil if not known
+func requestHeaderOrder(req *http.Request) []string {
+	if c, ok := req.Context().Value(connContextKey{}).(*requestConn); ok {
+		return c.heads.Take(req.Method, req.RequestURI)
+	}
+	return nil
+}
@@ -1,19 +1,19 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path"
 	"strings"
-	"sync"
+	"time"
 
 	bta "github.com/adedayo/browser-tls-audit/pkg"
 	homedir "github.com/mitchellh/go-homedir"
@@ -21,9 +21,6 @@ import (
 )
 
 var (
-	messageBus = make(chan interface{})
-	helloInfos = make(map[string]*tls.ClientHelloInfo) // remote address by hello info
-	helloMutex = sync.RWMutex{}
 	infoWriter = make(chan bta.TLSInfoAndAgent)
 	dataDir    = func() (dataHome string) {
 		if home, err := homedir.Dir(); err == nil {
@@ -49,8 +46,11 @@ var (
 		}
 		return
 	}()
-	domain, httpsPort, certificatePath, keyPath = getFlags()
-	certManager                                 = autocert.Manager{
+	domain, httpsPort, certificatePath, keyPath, helloTTL, maxHellos, storeKind, offerHTTP2, agentRulesFile = getFlags()
+	correlator                                                                                              = bta.NewCorrelator(helloTTL, maxHellos)
+	store                                                                                                   = openStore()
+	classifier                                                                                              = bta.NewClassifier(nil)
+	certManager                                                                                             = autocert.Manager{
 		Prompt:     autocert.AcceptTOS,
 		HostPolicy: autocert.HostWhitelist(strings.Split(domain, ",")...),
 		Cache:      autocert.DirCache(certCachePath),
@@ -59,73 +59,76 @@ var (
 
 func main() {
 	fmt.Printf("Bound to domain %s using HTTPS port %d\n", domain, httpsPort)
+	loadAgentRules()
+	trainClassifier()
 	go rawTLS(httpsPort - 1)
 	go https(httpsPort)
-	go readMessages()
 	writeMessages()
 }
 
-func getFlags() (domain string, port int, cert, key string) {
+func getFlags() (domain string, port int, cert, key string, ttl time.Duration, maxHellos int, storeKind string, http2 bool, rules string) {
 	dd := flag.String("domain", "hostname", "The public domain name of this server")
 	pp := flag.Int("port", 443, "The HTTPS port. The the raw TLS server socket is the (HTTPS port) - 1")
 	cc := flag.String("cert", "", "The certificate file to use (optional), will attempt to get a cert from Letsencrypt if not specified")
 	kk := flag.String("key", "", "The certificate key file to use (optional), will attempt to get a key from Letsencrypt if not specified")
+	tt := flag.Duration("hello-ttl", 5*time.Minute, "How long a ClientHello is held waiting for the browser's audit request")
+	mm := flag.Int("max-hellos", 100000, "The maximum number of ClientHellos held waiting for audit requests")
+	ss := flag.String("store", bta.JSONLinesStoreKind, fmt.Sprintf("Where captured records are kept: %s (JSON lines file) or %s (embedded database)", bta.JSONLinesStoreKind, bta.BoltStoreKind))
+	hh := flag.Bool("http2", false, "Offer HTTP/2. Requests over HTTP/2 are recorded without JA4H, as their header order is not known")
+	rr := flag.String("rules", "", "A JSON file of user agent rules to use instead of the built in ones")
 	flag.Parse()
-	return *dd, *pp, *cc, *kk
+	return *dd, *pp, *cc, *kk, *tt, *mm, *ss, *hh, *rr
 }
 
-func writeMessages() {
-	out, err := os.OpenFile(path.Join(dataDir, "browser-data.json"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+//loadAgentRules applies the user agent rules file, if any, before any client is described
+func loadAgentRules() {
+	if agentRulesFile == "" {
+		return
+	}
+	rules, err := bta.LoadAgentRules(agentRulesFile)
 	if err != nil {
 		log.Fatal(err)
-		return
 	}
-	defer out.Close()
+	bta.SetAgentRules(rules)
+}
 
-	buff := bytes.Buffer{}
-	writer := bufio.NewWriter(&buff)
-	tee := io.MultiWriter(out, writer)
-	je := json.NewEncoder(tee)
+func openStore() bta.Store {
+	s, err := bta.OpenStore(storeKind, dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s
+}
 
-	for info := range infoWriter {
-		je.Encode(info)
-		writer.Flush()
-		out.Sync()
+//trainClassifier teaches the classifier behind /identify the browsers recorded so far, leaving out captures flagged as spoofed
+func trainClassifier() {
+	err := store.Iterate(context.Background(), bta.ReadOptions{SkipMalformed: true}, func(info bta.TLSInfoAndAgent) error {
+		classifier.Learn(info)
+		return nil
+	})
+	if err != nil {
+		log.Println(err)
 	}
+	fmt.Printf("Identifying browsers from %d records\n", classifier.Records())
 }
 
-func readMessages() {
-	for event := range messageBus {
-		switch data := event.(type) {
-		case bta.RemoteAddressAndAgent:
-			helloMutex.RLock()
-			if hello, present := helloInfos[data.Remote]; present {
-				infoWriter <- bta.TLSInfoAndAgent{
-					Agent:     data.Agent,
-					HelloInfo: hello,
-				}
-			} // else ignore agent without pior tls info
-
-			helloMutex.RUnlock()
-		case *tls.ClientHelloInfo:
-			helloMutex.Lock()
-			address := data.Conn.RemoteAddr().String()
-			if _, present := helloInfos[address]; !present {
-				helloInfos[address] = data
-			}
-			helloMutex.Unlock()
-		default:
-			fmt.Printf("Unknown type %#v\n", data)
+func writeMessages() {
+	defer store.Close()
+	for info := range infoWriter {
+		if err := store.Append(info); err != nil {
+			log.Println(err)
 		}
+		classifier.Learn(info)
 	}
 }
 
 func rawTLS(port int) {
 	conf := getTLSConfig()
-	conn, err := tls.Listen("tcp", fmt.Sprintf(":%d", port), conf)
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		log.Fatal(err)
 	}
+	conn := tls.NewListener(helloListener{ln}, conf)
 	defer conn.Close()
 
 	for {
@@ -141,15 +144,29 @@ func https(port int) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/browserAudit", auditBrowser)
 	mux.HandleFunc("/browserTLSResults", showResults)
+	mux.HandleFunc("/browserAuditStats", showStats)
+	mux.HandleFunc("/identify", identify)
+	conf := getTLSConfig()
+	conf.NextProtos = []string{"http/1.1"}
+	if offerHTTP2 {
+		conf.NextProtos = []string{"h2", "http/1.1"}
+	}
 	server := &http.Server{
 		Addr:      fmt.Sprintf(":%d", port),
 		Handler:   mux,
-		TLSConfig: getTLSConfig(),
+		TLSConfig: conf,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connContextKey{}, c)
+		},
 	}
 
 	go http.ListenAndServe(":http", certManager.HTTPHandler(nil))
 
-	log.Fatal(server.ListenAndServeTLS("", ""))
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Fatal(server.Serve(newRequestListener(helloListener{ln}, conf)))
 }
 
 func getTLSConfig() *tls.Config {
@@ -182,33 +199,100 @@ func getLocalOrAutoCert() func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
 }
 
 func showResults(w http.ResponseWriter, req *http.Request) {
-	out := bta.GetRawData(dataDir)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(out)
+	w.Write([]byte("["))
+	count := 0
+	err := store.Iterate(req.Context(), bta.ReadOptions{SkipMalformed: true}, func(info bta.TLSInfoAndAgent) error {
+		js, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			w.Write([]byte(",\n"))
+		}
+		count++
+		_, err = w.Write(js)
+		return err
+	})
+	if err != nil {
+		log.Println(err)
+	}
+	w.Write([]byte("]\n"))
+}
+
+func showStats(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(correlator.Stats())
 }
 
-func auditBrowser(w http.ResponseWriter, req *http.Request) {
-	messageBus <- bta.RemoteAddressAndAgent{
-		Remote: req.RemoteAddr,
-		Agent:  req.UserAgent(),
+//identify ranks the browsers that may have sent a ClientHello. A POST identifies the ClientHello of a record in the JSON format
+//of /browserTLSResults, with either its HelloInfo or its raw ClientHello, and a GET identifies the requester's own connection
+func identify(w http.ResponseWriter, req *http.Request) {
+	info := bta.TLSInfoAndAgent{}
+	switch req.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(io.LimitReader(req.Body, maxHelloCapture)).Decode(&info); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodGet:
+		if id, ok := requestConnectionID(req); ok {
+			info, _ = correlator.Match(id)
+		}
+	default:
+		http.Error(w, "Expects a GET or POST", http.StatusMethodNotAllowed)
+		return
 	}
+	var id bta.Identification
+	switch {
+	case info.ClientHello != nil:
+		id = classifier.IdentifyClientHello(info.ClientHello)
+	case info.HelloInfo != nil:
+		id = classifier.Identify(info.HelloInfo)
+	default:
+		http.Error(w, "Expects a record with a HelloInfo or ClientHello", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(id)
+}
 
-	address := req.RemoteAddr
-	helloMutex.RLock()
-	if hello, present := helloInfos[address]; present {
-		data := bta.TLSInfoAndAgent{
-			Agent:     req.UserAgent(),
-			HelloInfo: hello,
-		}
+func auditBrowser(w http.ResponseWriter, req *http.Request) {
+	conn, ok := requestHelloConn(req)
+	if !ok {
+		return
+	}
+	//ask for the Client Hints that describe a browser beyond its frozen user agent
+	hints := strings.Join(bta.ClientHintHeaders, ", ")
+	w.Header().Set("Accept-CH", hints)
+	w.Header().Set("Critical-CH", hints)
+	if req.Header.Get("Sec-CH-UA") != "" && req.Header.Get("Sec-CH-UA-Full-Version-List") == "" &&
+		req.Header.Get("Sec-Fetch-Mode") == "navigate" && conn.deferHints() {
+		//a browser supporting Client Hints may retry a navigation with the critical hints, keep the ClientHello for the retry.
+		//Fetches are not retried and some browsers withhold the hints, so anything else is recorded with the hints that arrived
+		return
+	}
+	if data, present := correlator.Match(conn.id); present {
+		data.Timestamp = time.Now()
+		data.Agent = req.UserAgent()
+		data.HTTPRequest = bta.NewHTTPRequestInfo(req, requestHeaderOrder(req))
+		data.ClientHints = bta.NewClientHints(req.Header)
+		verdict := bta.DetectSpoofing(data, classifier)
+		data.Spoofing = &verdict
+		infoWriter <- data
 		if js, err := json.Marshal(data); err == nil {
 			w.Header().Set("Content-Type", "text/html")
 			w.Write(js)
 		}
-	}
-	helloMutex.RUnlock()
+	} // else ignore agent without prior tls info
 }
 
 func clientConfigGetter(helloInfo *tls.ClientHelloInfo) (*tls.Config, error) {
-	messageBus <- helloInfo
+	if conn, ok := helloInfo.Conn.(*helloConn); ok {
+		correlator.AddHello(conn.id, bta.TLSInfoAndAgent{
+			HelloInfo:   helloInfo,
+			ClientHello: conn.ClientHello(),
+		})
+	}
 	return nil, nil
 }
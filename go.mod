@@ -1,9 +1,12 @@
 module github.com/adedayo/browser-tls-audit
 
-go 1.12
+go 1.16
 
 require (
 	github.com/adedayo/tls-definitions v0.0.2
 	github.com/mitchellh/go-homedir v1.1.0
-	golang.org/x/crypto v0.0.0-20190617133340-57b3e21c3d56
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
+	go.etcd.io/bbolt v1.3.6
+	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
 )
@@ -1,178 +1,266 @@
 package model
 
 import (
+	_ "embed" //for the default user agent rules
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"regexp"
-	"strings"
+	"sort"
+	"sync"
 )
 
+//go:embed rules/user-agents.json
+var defaultAgentRules []byte
+
 var (
-	chrome   = regexp.MustCompile(`Chrome/(\d{1,3}[.]\d{1,3})(?:[.]\d{1,3}){,3} Safari/\d{1,3}[.]\d{1,3}(?:[.]\d{1,3}){,3}$`)
-	ff       = regexp.MustCompile(`Firefox/(\d{1,3}[.]\d{1,3}(?:[.]\d{1,3})?)$`)
-	opr      = regexp.MustCompile(`OPR/(\d{1,3}[.]\d{1,3}[.]\d{1,4}[.]\d{1,3})$`)
-	sfr      = regexp.MustCompile(`Safari/(\d{1,3}[.]\d{1,3}(?:[.]\d{1,3})?)$`)
-	opr2     = regexp.MustCompile(`^Opera/\d{1,3}[.]\d{1,3}.*Version/(\d\d)[.].*`)
-	ie       = regexp.MustCompile(`MSIE (\d{1,3}[.]\d{1,3})`)
-	ie2      = regexp.MustCompile(`rv:(\d{1,3}[.]\d{1,3})\) like Gecko`)
-	mobie    = regexp.MustCompile(`IEMobile/(\d{1,3}[.]\d{1,3})`)
-	midori   = regexp.MustCompile(`Midori/(\d{1,3}[.]\d{1,3})$`)
-	konq     = regexp.MustCompile(`Konqueror/(\d{1,3}[.]\d{1,3})$`)
-	seamonk  = regexp.MustCompile(`SeaMonkey/(\d{1,3}[.]\d{1,3})(?:[.]\d{1,3})?$`)
-	epiphany = regexp.MustCompile(`Epiphany/(\d[.])(?:\d{1,3}[.]\d{1,3})$`)
-	iceape   = regexp.MustCompile(`Iceape/(\d[.]\d{1,3})(?:[.]\d{1,3})$`)
-	gshot    = regexp.MustCompile(`GrapeshotCrawler/(\d{1,3}[.]\d{1,3})`)
-
-	osSignatures = map[string]string{
-		"Intel Mac OS X 10.14":         "Mac OS X (Mojave)",
-		"Intel Mac OS X 10_14_":        "Mac OS X (Mojave)",
-		"Intel Mac OS X 10.13":         "Mac OS X (High Sierra)",
-		"Intel Mac OS X 10_13_":        "Mac OS X (High Sierra)",
-		"Intel Mac OS X 10.12":         "Mac OS X (El Capitan)",
-		"Intel Mac OS X 10_12_":        "Mac OS X (El Capitan)",
-		"Intel Mac OS X 10.11":         "Mac OS X (Sierra)",
-		"Intel Mac OS X 10_11_":        "Mac OS X (Sierra)",
-		"Intel Mac OS X 10.10":         "Mac OS X (Yosemite)",
-		"Intel Mac OS X 10_10_":        "Mac OS X (Yosemite)",
-		"Intel Mac OS X 10.9":          "Mac OS X (Mavericks)",
-		"Intel Mac OS X 10_9_":         "Mac OS X (Mavericks)",
-		"Intel Mac OS X 10.8":          "Mac OS X (Mountain Lion)",
-		"Intel Mac OS X 10_8_":         "Mac OS X (Mountain Lion)",
-		"Intel Mac OS X 10.7":          "Mac OS X (Lion)",
-		"Intel Mac OS X 10_7_":         "Mac OS X (Lion)",
-		"Intel Mac OS X 10.6":          "Mac OS X (Snow Leopard)",
-		"Intel Mac OS X 10_6_":         "Mac OS X (Snow Leopard)",
-		"Macintosh; U; Intel Mac OS X": "Mac OS X",
-		"Windows NT 10.0":              "Windows 10",
-		"Windows NT 6.3":               "Windows 8.1",
-		"Windows NT 6.2":               "Windows 8",
-		"Windows NT 6.1":               "Windows 7",
-		"Windows NT 5.2":               "Windows Server 2003",
-		"Mobile; Windows Phone 8.1":    "Windows Phone 8.1",
-		"CPU iPhone OS 11_4":           "iOS 11.4",
-		"CPU iPhone OS 11_3":           "iOS 11.3",
-		"CPU iPhone OS 11_2":           "iOS 11.2",
-		"CPU iPhone OS 11_1":           "iOS 11.1",
-		"CPU iPhone OS 11_0":           "iOS 11",
-		"CPU iPhone OS 10_3":           "iOS 10.3",
-		"CPU iPhone OS 9_3":            "iOS 9.3",
-		"CPU iPhone OS 9_2":            "iOS 9.2",
-		"CPU iPhone OS 9_1":            "iOS 9.1",
-		"CPU iPhone OS 9_0_1":          "iOS 9",
-		"iPad; CPU OS 12_1":            "iOS 12.1",
-		"iPad; CPU OS 11_0":            "iOS 11",
-		"iPad; CPU OS 11_2":            "iOS 11.2",
-		"iPad; CPU OS 11_4":            "iOS 11.4",
-		"iPad; CPU OS 11_3":            "iOS 11.3",
-		"iPad; CPU OS 8_1":             "iOS 8.1",
-		"CPU iPhone OS 12_1":           "iOS 12.1",
-		"X11; Linux":                   "Linux",
-		"X11; Ubuntu; Linux":           "Linux",
-		"Linux i686":                   "Linux",
-		"Linux; Android 9":             "Android 9",
-		"Linux; Android 8.":            "Android 8",
-		"Linux; Android 7.":            "Android 7",
-		"Linux; Android 6.":            "Android 6",
-		"Linux; Android 5.":            "Android 5",
-		"Linux; Android 4.":            "Android 4",
-		"Grapeshot":                    "Grapeshot",
-		"Browsershots":                 "Browsershots",
-	}
+	agentRulesMutex sync.RWMutex
+	agentRules      = func() *AgentRules {
+		rules, err := ParseAgentRules(defaultAgentRules)
+		if err != nil {
+			panic(err)
+		}
+		return rules
+	}()
 )
 
-type browser struct {
-	name, version, os string
+//Device classes of ClientDescription
+const (
+	DeviceDesktop = "desktop"
+	DevicePhone   = "phone"
+	DeviceTablet  = "tablet"
+	DeviceTV      = "tv"
+	DeviceBot     = "bot"
+)
+
+//AgentRule recognises an operating system, browser, rendering engine, device class or CPU architecture from a user agent
+type AgentRule struct {
+	Pattern    string //a regular expression matched against the user agent
+	Name       string //the operating system, browser, engine, device class or architecture, which may refer to the pattern's capture groups as $1 or ${1}
+	Version    string //the version of the browser, engine or operating system, usually a capture group such as $1
+	Chromium   string //the version of Chromium a browser is built on, usually from its Chrome token. Empty for other engines
+	Family     string //the family of an operating system, e.g. Windows, macOS or Android
+	Precedence int    //rules of higher precedence are tried first, and rules of equal precedence in the order they are listed
+
+	re *regexp.Regexp
 }
 
-func getBrowserVersionAndOS(ua string) (b browser, e error) {
-	if os, err := getOS(ua); err == nil {
-		b.os = os
-	} else {
-		return b, err
-	}
+//AgentRules are the ordered rules describing a user agent. The first matching rule of each kind wins.
+//A user agent is recognised if its operating system and browser are; the other kinds are left empty if no rule matches,
+//except the device class, which defaults to desktop
+type AgentRules struct {
+	OS            []AgentRule
+	Browsers      []AgentRule
+	Engines       []AgentRule
+	Devices       []AgentRule
+	Architectures []AgentRule
+}
 
-	if br, ver, err := getBrowserAndVersion(ua); err == nil {
-		b.name = br
-		b.version = ver
-		return b, nil
+//ParseAgentRules reads rules from JSON of the form {"OS": [rules], "Browsers": [rules], "Engines": [rules], "Devices": [rules],
+//"Architectures": [rules]}, ordering each kind by precedence
+func ParseAgentRules(data []byte) (*AgentRules, error) {
+	rules := AgentRules{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	if err := compileRules("OS", rules.OS); err != nil {
+		return nil, err
+	}
+	if err := compileRules("browser", rules.Browsers); err != nil {
+		return nil, err
 	}
-	return b, fmt.Errorf("Unknown browser from user agent: %s", ua)
+	if err := compileRules("engine", rules.Engines); err != nil {
+		return nil, err
+	}
+	if err := compileRules("device", rules.Devices); err != nil {
+		return nil, err
+	}
+	if err := compileRules("architecture", rules.Architectures); err != nil {
+		return nil, err
+	}
+	return &rules, nil
 }
 
-func getOS(ua string) (string, error) {
-	for s, os := range osSignatures {
-		if strings.Contains(ua, s) {
-			return os, nil
+//compileRules compiles the patterns of a kind of rules and orders them by precedence
+func compileRules(kind string, rules []AgentRule) error {
+	for i, r := range rules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid %s rule %d: %s", kind, i, err.Error())
+		}
+		if r.Name == "" {
+			return fmt.Errorf("Expects a name in %s rule %d", kind, i)
 		}
+		rules[i].re = re
 	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Precedence > rules[j].Precedence })
+	return nil
+}
 
-	return "", fmt.Errorf("Unknown OS from user agent: %s", ua)
+//LoadAgentRules reads user agent rules from a JSON file, see ParseAgentRules
+func LoadAgentRules(file string) (*AgentRules, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseAgentRules(data)
 }
 
-func getBrowserAndVersion(ua string) (string, string, error) {
+//DefaultAgentRules are the built in user agent rules
+func DefaultAgentRules() *AgentRules {
+	rules, _ := ParseAgentRules(defaultAgentRules)
+	return rules
+}
 
-	if matches := chrome.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Chrome", matches[1], nil
-	}
+//SetAgentRules replaces the rules used to describe clients from their user agents, e.g. by ParseClientDescription
+func SetAgentRules(rules *AgentRules) {
+	agentRulesMutex.Lock()
+	defer agentRulesMutex.Unlock()
+	agentRules = rules
+}
 
-	if matches := ff.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Firefox", matches[1], nil
-	}
+func currentAgentRules() *AgentRules {
+	agentRulesMutex.RLock()
+	defer agentRulesMutex.RUnlock()
+	return agentRules
+}
 
-	if matches := sfr.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Safari", matches[1], nil
+//match finds the first rule matching the agent, expanding its name and versions
+func match(rules []AgentRule, ua string) (b browser, ok bool) {
+	for _, r := range rules {
+		if m := r.re.FindStringSubmatchIndex(ua); m != nil {
+			b.name = string(r.re.ExpandString(nil, r.Name, ua, m))
+			b.version = string(r.re.ExpandString(nil, r.Version, ua, m))
+			b.chromium = string(r.re.ExpandString(nil, r.Chromium, ua, m))
+			b.family = string(r.re.ExpandString(nil, r.Family, ua, m))
+			return b, true
+		}
 	}
+	return
+}
 
-	if matches := opr.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Opera", matches[1], nil
-	}
+//browser is what a rule recognises: the name and version of a browser, or of an operating system and its family
+type browser struct {
+	name, version, chromium, family, os string
+}
 
-	if matches := opr2.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Opera", matches[1], nil
+func getBrowserVersionAndOS(ua string) (b browser, e error) {
+	rules := currentAgentRules()
+	if os, err := rules.getOS(ua); err == nil {
+		b.os = os.name
+	} else {
+		return b, err
 	}
 
-	if matches := ie.FindStringSubmatch(ua); len(matches) == 2 {
-		return "IE", matches[1], nil
+	br, err := rules.getBrowserAndVersion(ua)
+	if err != nil {
+		return b, err
 	}
+	br.os = b.os
+	return br, nil
+}
 
-	if matches := ie2.FindStringSubmatch(ua); len(matches) == 2 {
-		return "IE", matches[1], nil
+//describe recognises the browser, operating system, engine, device class and architecture of a user agent
+func (rules *AgentRules) describe(ua string) (desc ClientDescription, err error) {
+	os, err := rules.getOS(ua)
+	if err != nil {
+		return desc, err
 	}
-
-	if matches := mobie.FindStringSubmatch(ua); len(matches) == 2 {
-		return "IE Mobile", matches[1], nil
+	br, err := rules.getBrowserAndVersion(ua)
+	if err != nil {
+		return desc, err
 	}
-
-	if matches := iceape.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Iceape", matches[1], nil
+	desc.Browser = br.name
+	desc.BrowserVersion = br.version
+	desc.ChromiumVersion = br.chromium
+	desc.OS = os.name
+	desc.OSFamily = os.family
+	desc.OSVersion = os.version
+	if engine, ok := match(rules.Engines, ua); ok {
+		desc.Engine = engine.name
+		desc.EngineVersion = engine.version
 	}
-
-	if strings.Contains(ua, "Browsershots") {
-		return "Browsershots", "1", nil
+	desc.DeviceClass = DeviceDesktop
+	if device, ok := match(rules.Devices, ua); ok {
+		desc.DeviceClass = device.name
 	}
-
-	if matches := midori.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Midori", matches[1], nil
+	if arch, ok := match(rules.Architectures, ua); ok {
+		desc.Architecture = arch.name
 	}
+	desc.Bot = desc.DeviceClass == DeviceBot
+	return desc, nil
+}
 
-	if matches := konq.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Konqueror", matches[1], nil
+//getOS recognises the operating system by the first matching rule. Rules for the more specific platforms take precedence,
+//e.g. crawlers over the platforms they claim, Windows Phone over Android, Android over Linux and iPadOS over iOS
+func (rules *AgentRules) getOS(ua string) (browser, error) {
+	if os, ok := match(rules.OS, ua); ok {
+		return os, nil
 	}
+	return browser{}, &UnknownAgentError{Agent: ua, Part: "OS"}
+}
 
-	if matches := seamonk.FindStringSubmatch(ua); len(matches) == 2 {
-		return "SeaMonkey", matches[1], nil
+//less orders client descriptions by browser, version and operating system, then by their other properties.
+//Versions are compared as versions rather than text, so Chrome 9.0 comes before Chrome 10.0
+func (d ClientDescription) less(o ClientDescription) bool {
+	if d.Browser != o.Browser {
+		return d.Browser < o.Browser
 	}
-
-	if matches := epiphany.FindStringSubmatch(ua); len(matches) == 2 {
-		return "Epiphany", matches[1], nil
+	if c := compareVersions(d.Browser, d.BrowserVersion, o.BrowserVersion); c != 0 {
+		return c < 0
 	}
-
-	if matches := gshot.FindStringSubmatch(ua); len(matches) == 2 {
-		return "GrapeshotCrawler", matches[1], nil
+	if d.OS != o.OS {
+		if d.OSFamily == o.OSFamily {
+			if c := compareVersions("", d.OSVersion, o.OSVersion); c != 0 {
+				return c < 0
+			}
+		}
+		return d.OS < o.OS
 	}
+	return fmt.Sprint(d) < fmt.Sprint(o)
+}
+
+//aggregate is the part of a description that records are grouped by: the browser, its versions and the operating system.
+//The engine, device class, architecture and the like are left out so that they do not split the records of a browser,
+//e.g. 32 and 64 bit Firefox 60 on Windows 7
+func (d ClientDescription) aggregate() ClientDescription {
+	return ClientDescription{Browser: d.Browser, BrowserVersion: d.BrowserVersion, ChromiumVersion: d.ChromiumVersion, OS: d.OS}
+}
 
-	if strings.Contains(ua, "WebKit") {
-		return "WebKit", "0", nil
+func (rules *AgentRules) getBrowserAndVersion(ua string) (browser, error) {
+	if br, ok := match(rules.Browsers, ua); ok {
+		return br, nil
 	}
+	return browser{}, &UnknownAgentError{Agent: ua, Part: "browser"}
+}
+
+//UnrecognisedAgent is a user agent whose operating system or browser no rule recognises
+type UnrecognisedAgent struct {
+	Agent   string
+	Part    string //OS or browser
+	Records int
+}
 
-	return "", "", fmt.Errorf("Unknown browser name or browser version from user agent: %s", ua)
+//UnrecognisedAgents lists the user agents of the records that cannot be described, the most frequent first, to seed new rules
+func UnrecognisedAgents(infos []TLSInfoAndAgent) (out []UnrecognisedAgent) {
+	index := map[string]int{} //the position of an unrecognised agent in out, -1 for those recognised
+	for _, info := range infos {
+		if i, seen := index[info.Agent]; seen {
+			if i >= 0 {
+				out[i].Records++
+			}
+			continue
+		}
+		index[info.Agent] = -1
+		if _, err := getBrowserVersionAndOS(info.Agent); err != nil {
+			part := ""
+			if unknown, ok := err.(*UnknownAgentError); ok {
+				part = unknown.Part
+			}
+			index[info.Agent] = len(out)
+			out = append(out, UnrecognisedAgent{Agent: info.Agent, Part: part, Records: 1})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Records > out[j].Records })
+	return
 }
@@ -2,6 +2,7 @@ package model
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"os"
@@ -38,3 +39,183 @@ func TestBrowserfromUserAgent(t *testing.T) {
 	}
 
 }
+
+func TestParseClientDescriptionUnknown(t *testing.T) {
+	agents := map[string]string{
+		"curl/7.64.1": "OS",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) UnknownBrowser/1.0": "browser",
+	}
+	for agent, part := range agents {
+		_, err := ParseClientDescription(agent)
+		unknown := &UnknownAgentError{}
+		if !errors.As(err, &unknown) || unknown.Part != part || unknown.Agent != agent {
+			t.Errorf("Expected an unknown %s error for %s, got %v", part, agent, err)
+		}
+	}
+}
+
+func TestParseAgentRules(t *testing.T) {
+	rules, err := ParseAgentRules([]byte(`{
+		"OS": [
+			{"Pattern": "Linux", "Name": "Linux"},
+			{"Pattern": "Android (\\d+)", "Name": "Android $1", "Precedence": 1}
+		],
+		"Browsers": [{"Pattern": "Chrome/(\\d+)\\.(\\d+)", "Name": "Chrome", "Version": "$1.${2}"}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetAgentRules(rules)
+	defer SetAgentRules(DefaultAgentRules())
+	desc, err := ParseClientDescription("Mozilla/5.0 (Linux; Android 9; Pixel 3) Chrome/76.0.3809.89 Mobile")
+	if err != nil || desc != (ClientDescription{Browser: "Chrome", BrowserVersion: "76.0", OS: "Android 9", DeviceClass: DeviceDesktop}) {
+		t.Errorf("Unexpected description %+v %v", desc, err)
+	}
+
+	for _, invalid := range []string{`{"OS": [{"Pattern": "(", "Name": "Broken"}]}`, `{"Browsers": [{"Pattern": "Chrome"}]}`, `[]`} {
+		if _, err := ParseAgentRules([]byte(invalid)); err == nil {
+			t.Errorf("Expects an error for the rules %s", invalid)
+		}
+	}
+}
+
+func TestUnrecognisedAgents(t *testing.T) {
+	chrome := "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.100 Safari/537.36"
+	infos := []TLSInfoAndAgent{{Agent: "curl/7.64.1"}, {Agent: chrome}, {Agent: "Mozilla/5.0 (X11; Linux x86_64) UnknownBrowser/1.0"},
+		{Agent: "curl/7.64.1"}, {Agent: chrome}}
+	unknown := UnrecognisedAgents(infos)
+	if len(unknown) != 2 || unknown[0] != (UnrecognisedAgent{Agent: "curl/7.64.1", Part: "OS", Records: 2}) || unknown[1].Part != "browser" {
+		t.Errorf("Unexpected unrecognised agents %+v", unknown)
+	}
+}
+
+// TestOSRulesUnambiguous checks that the operating system of every recorded agent is decided by precedence alone: no two
+// rules of the highest precedence matching an agent describe it differently, leaving the answer to the order they are listed
+func TestOSRulesUnambiguous(t *testing.T) {
+	infos, err := GetRawData("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := DefaultAgentRules()
+	seen := map[string]bool{}
+	for _, info := range infos {
+		if seen[info.Agent] {
+			continue
+		}
+		seen[info.Agent] = true
+		var first *browser
+		precedence := 0
+		for _, r := range rules.OS {
+			if first != nil && r.Precedence < precedence {
+				break
+			}
+			os, ok := match([]AgentRule{r}, info.Agent)
+			if !ok {
+				continue
+			}
+			if first == nil {
+				first, precedence = &os, r.Precedence
+			} else if os != *first {
+				t.Errorf("Expects one OS of precedence %d for %s, got %s and %s", precedence, info.Agent, first.name, os.name)
+			}
+		}
+	}
+}
+
+func TestOSPrecedence(t *testing.T) {
+	agents := map[string]string{
+		"Mozilla/5.0 (X11; Linux x86_64; Linux; Android 9; Pixel 3) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.101 Mobile Safari/537.36":                                           "Android 9",
+		"Mozilla/5.0 (Linux; U; Android 4.0.3; ko-kr; LG-L160L Build/IML74K) AppleWebkit/534.30 (KHTML, like Gecko) Version/4.0 Mobile Safari/534.30":                                           "Android 4",
+		"Mozilla/5.0 (iPad; CPU OS 13_2 like Mac OS X; iPhone) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.3 Mobile/15E148 Safari/604.1":                                              "iPadOS 13.2",
+		"Mozilla/5.0 (iPad; CPU OS 12_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.0 Mobile/15E148 Safari/604.1":                                                        "iOS 12.1",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 11_0 like Mac OS X) AppleWebKit/604.1.38 (KHTML, like Gecko) Version/11.0 Mobile/15A372 Safari/604.1":                                               "iOS 11",
+		"Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1; Windows NT 10.0; Microsoft; Lumia 950) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Mobile Safari/537.36 Edge/15.15063": "Windows Phone 10.0",
+	}
+	for agent, os := range agents {
+		if got, err := currentAgentRules().getOS(agent); err != nil || got.name != os {
+			t.Errorf("Expects %s for %s, got %s %v", os, agent, got.name, err)
+		}
+	}
+}
+
+func TestChromiumBrands(t *testing.T) {
+	agents := map[string]ClientDescription{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/76.0.3809.100 Safari/537.36 Edg/76.0.182.42": {
+			Browser: "Edge", BrowserVersion: "76.0", ChromiumVersion: "76.0", OS: "Windows 10"},
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.17763": {
+			Browser: "Edge", BrowserVersion: "18.17763", OS: "Windows 10"},
+		"Mozilla/5.0 (Linux; Android 9; SAMSUNG SM-G960F) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/9.2 Chrome/67.0.3396.87 Mobile Safari/537.36": {
+			Browser: "Samsung Internet", BrowserVersion: "9.2", ChromiumVersion: "67.0", OS: "Android 9"},
+		"Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.142 YaBrowser/19.9.0.1343 Yowser/2.5 Safari/537.36": {
+			Browser: "Yandex", BrowserVersion: "19.9", ChromiumVersion: "75.0", OS: "Windows 7"},
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/76.0.3809.100 Safari/537.36 Vivaldi/2.7.1628.30": {
+			Browser: "Vivaldi", BrowserVersion: "2.7", ChromiumVersion: "76.0", OS: "Linux"},
+		"Mozilla/5.0 (Linux; Android 9; Pixel 3) AppleWebKit/537.36 (KHTML, like Gecko) Brave Chrome/76.0.3809.89 Mobile Safari/537.36": {
+			Browser: "Brave", BrowserVersion: "76.0", ChromiumVersion: "76.0", OS: "Android 9"},
+		"Mozilla/5.0 (Linux; U; Android 8.1.0; en-US; Nexus 6P Build/OPM7.181205.001) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/57.0.2987.108 UCBrowser/12.11.1.1197 Mobile Safari/537.36": {
+			Browser: "UC Browser", BrowserVersion: "12.11", ChromiumVersion: "57.0", OS: "Android 8"},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.100 Safari/537.36 OPR/62.0.3331.72": {
+			Browser: "Opera", BrowserVersion: "62.0.3331.72", ChromiumVersion: "75.0", OS: "Mac OS X (Mojave)"},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.100 Safari/537.36": {
+			Browser: "Chrome", BrowserVersion: "75.0", ChromiumVersion: "75.0", OS: "Mac OS X (Mojave)"},
+	}
+	for agent, expected := range agents {
+		desc, err := ParseClientDescription(agent)
+		brand := ClientDescription{Browser: desc.Browser, BrowserVersion: desc.BrowserVersion, ChromiumVersion: desc.ChromiumVersion, OS: desc.OS}
+		if err != nil || brand != expected {
+			t.Errorf("Expects %+v for %s, got %+v %v", expected, agent, desc, err)
+		}
+	}
+}
+
+func TestClientDescriptionDetails(t *testing.T) {
+	agents := map[string]ClientDescription{
+		"Mozilla/5.0 (Windows NT 6.1; WOW64; rv:60.0) Gecko/20100101 Firefox/60.0": {Browser: "Firefox", BrowserVersion: "60.0",
+			Engine: "Gecko", EngineVersion: "60.0", OS: "Windows 7", OSFamily: "Windows", OSVersion: "7", DeviceClass: DeviceDesktop, Architecture: "WOW64"},
+		"Mozilla/5.0 (Windows NT 6.3; Trident/7.0; rv:11.0) like Gecko": {Browser: "IE", BrowserVersion: "11.0",
+			Engine: "Trident", EngineVersion: "7.0", OS: "Windows 8.1", OSFamily: "Windows", OSVersion: "8.1", DeviceClass: DeviceDesktop},
+		"Mozilla/5.0 (iPad; CPU OS 12_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.0 Mobile/15E148 Safari/604.1": {
+			Browser: "Safari", BrowserVersion: "12.0", Engine: "WebKit", OS: "iOS 12.1", OSFamily: "iOS", OSVersion: "12.1", DeviceClass: DeviceTablet},
+		"Mozilla/5.0 (Linux; Android 9; SM-T830) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.101 Safari/537.36": {
+			Browser: "Chrome", BrowserVersion: "75.0", ChromiumVersion: "75.0", Engine: "Blink", EngineVersion: "75.0", OS: "Android 9",
+			OSFamily: "Android", OSVersion: "9", DeviceClass: DeviceTablet},
+		"Mozilla/5.0 (X11; Linux aarch64; rv:67.0) Gecko/20100101 Firefox/67.0": {Browser: "Firefox", BrowserVersion: "67.0",
+			Engine: "Gecko", EngineVersion: "67.0", OS: "Linux", OSFamily: "Linux", DeviceClass: DeviceDesktop, Architecture: "arm64"},
+		"Mozilla/5.0 (compatible; GrapeshotCrawler/2.0; +http://www.grapeshot.co.uk/crawler.php)": {Browser: "GrapeshotCrawler", BrowserVersion: "2.0",
+			OS: "Grapeshot", DeviceClass: DeviceBot, Bot: true},
+	}
+	for agent, expected := range agents {
+		if desc, err := ParseClientDescription(agent); err != nil || desc != expected {
+			t.Errorf("Expects %+v for %s, got %+v %v", expected, agent, desc, err)
+		}
+	}
+}
+
+func TestDefaultAgentRules(t *testing.T) {
+	agents := map[string]ClientDescription{
+		//Chrome on phones and with 4 digit versions
+		"Mozilla/5.0 (Linux; Android 10; Pixel 3) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/83.0.4103.106 Mobile Safari/537.36": {Browser: "Chrome", BrowserVersion: "83.0", OS: "Android 10"},
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/1024.0.4103.106 Safari/537.36":               {Browser: "Chrome", BrowserVersion: "1024.0", OS: "Linux"},
+		//macOS 10.12 is Sierra and 10.11 El Capitan
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_12_6) AppleWebKit/603.3.8 (KHTML, like Gecko) Version/10.1.2 Safari/603.3.8":    {Browser: "Safari", BrowserVersion: "10.1", OS: "Mac OS X (Sierra)"},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.11; rv:68.0) Gecko/20100101 Firefox/68.0":                                       {Browser: "Firefox", BrowserVersion: "68.0", OS: "Mac OS X (El Capitan)"},
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.75 Safari/537.36": {Browser: "Chrome", BrowserVersion: "86.0", OS: "Mac OS X (Catalina)"},
+		//operating systems without a rule before the rules file
+		"Mozilla/5.0 (Windows NT 6.0; rv:52.0) Gecko/20100101 Firefox/52.0":                                                                                                            {Browser: "Firefox", BrowserVersion: "52.0", OS: "Windows Vista"},
+		"Mozilla/5.0 (Windows NT 5.1; rv:52.0) Gecko/20100101 Firefox/52.0":                                                                                                            {Browser: "Firefox", BrowserVersion: "52.0", OS: "Windows XP"},
+		"Mozilla/5.0 (X11; CrOS x86_64 13099.110.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/84.0.4147.136 Safari/537.36":                                                         {Browser: "Chrome", BrowserVersion: "84.0", OS: "Chrome OS"},
+		"Mozilla/5.0 (Linux; Android 11; SM-G991B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/90.0.4430.210 Mobile Safari/537.36":                                                   {Browser: "Chrome", BrowserVersion: "90.0", OS: "Android 11"},
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 13_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.4 Mobile/15E148 Safari/604.1":                                    {Browser: "Safari", BrowserVersion: "13.0", OS: "iOS 13.3"},
+		"Mozilla/5.0 (Mobile; Windows Phone 10.0; Android 6.0.1; Microsoft; Lumia 950) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Mobile Safari/537.36 Edge/15.15063": {Browser: "Edge", BrowserVersion: "15.15063", OS: "Windows Phone 10.0"},
+	}
+	for agent, want := range agents {
+		desc, err := ParseClientDescription(agent)
+		if err != nil {
+			t.Errorf("Expects %s to be recognised: %v", agent, err)
+			continue
+		}
+		if desc.Browser != want.Browser || (want.BrowserVersion != "" && desc.BrowserVersion != want.BrowserVersion) || desc.OS != want.OS {
+			t.Errorf("Expects %s %s on %s from %s, got %+v", want.Browser, want.BrowserVersion, want.OS, agent, desc)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"strings"
+
+	tlsdefs "github.com/adedayo/tls-definitions"
+)
+
+//CipherSuite describes the algorithms that make up a cipher suite, as parsed from its IANA name
+type CipherSuite struct {
+	ID             uint16
+	Name           string
+	KeyExchange    string //e.g. ECDHE, DHE, RSA or PSK. TLS 1.3 suites leave this to the key share, hence "any"
+	Authentication string //e.g. RSA, ECDSA, DSS or anon. "any" for TLS 1.3 suites
+	Cipher         string //the bulk cipher, e.g. AES_128_GCM, 3DES_EDE_CBC, RC4_128 or NULL
+	MAC            string //e.g. SHA, SHA256 or MD5. The PRF hash for AEAD suites
+	Export         bool   //an export grade suite
+	TLS13          bool   //a TLS 1.3 suite
+}
+
+//ParseCipherSuite looks up and describes the cipher suite id. It is false for unknown ids, GREASE and signalling values such as TLS_FALLBACK_SCSV
+func ParseCipherSuite(id uint16) (CipherSuite, bool) {
+	cs := CipherSuite{ID: id}
+	name, ok := tlsdefs.CipherSuiteMap[id]
+	if !ok || strings.HasSuffix(name, "_SCSV") {
+		return cs, false
+	}
+	cs.Name = name
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "TLS_"), "SSL_")
+	bulk := name
+	if parts := strings.SplitN(name, "_WITH_", 2); len(parts) == 2 {
+		bulk = parts[1]
+		kx := []string{}
+		for _, p := range strings.Split(parts[0], "_") {
+			if strings.HasPrefix(p, "EXPORT") {
+				cs.Export = true
+			} else {
+				kx = append(kx, p)
+			}
+		}
+		cs.KeyExchange = kx[0]
+		cs.Authentication = kx[0]
+		if len(kx) > 1 {
+			cs.Authentication = strings.Join(kx[1:], "_")
+		}
+	} else {
+		cs.TLS13 = true
+		cs.KeyExchange = "any"
+		cs.Authentication = "any"
+	}
+	tokens := strings.Split(bulk, "_")
+	switch last := tokens[len(tokens)-1]; last {
+	case "SHA", "SHA256", "SHA384", "MD5":
+		cs.MAC = last
+		tokens = tokens[:len(tokens)-1]
+	}
+	cs.Cipher = strings.Join(tokens, "_")
+	return cs, true
+}
+
+//AEAD reports whether the bulk cipher is an authenticated encryption (GCM, CCM or ChaCha20-Poly1305)
+func (cs CipherSuite) AEAD() bool {
+	return strings.Contains(cs.Cipher, "GCM") || strings.Contains(cs.Cipher, "CCM") || strings.Contains(cs.Cipher, "POLY1305")
+}
+
+//CBC reports whether the bulk cipher is a block cipher in CBC mode
+func (cs CipherSuite) CBC() bool {
+	return strings.Contains(cs.Cipher, "CBC")
+}
+
+//ForwardSecret reports whether the key exchange is ephemeral and authenticated
+func (cs CipherSuite) ForwardSecret() bool {
+	return cs.TLS13 || (cs.KeyExchange == "ECDHE" || cs.KeyExchange == "DHE") && cs.Authentication != "anon"
+}
+
+//Anonymous reports whether the suite does without server authentication
+func (cs CipherSuite) Anonymous() bool {
+	return cs.Authentication == "anon" || cs.KeyExchange == "NULL"
+}
+
+//Null reports whether the suite does without encryption
+func (cs CipherSuite) Null() bool {
+	return strings.HasPrefix(cs.Cipher, "NULL")
+}
+
+//RC4 reports whether the bulk cipher is RC4
+func (cs CipherSuite) RC4() bool {
+	return strings.HasPrefix(cs.Cipher, "RC4")
+}
+
+//TripleDES reports whether the bulk cipher is 3DES
+func (cs CipherSuite) TripleDES() bool {
+	return strings.HasPrefix(cs.Cipher, "3DES")
+}
+
+//DES reports whether the bulk cipher is single DES, including its 40 bit export variants
+func (cs CipherSuite) DES() bool {
+	return strings.HasPrefix(cs.Cipher, "DES")
+}
@@ -0,0 +1,287 @@
+package model
+
+import (
+	"crypto/tls"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//helloFeatures are what a ClientHello offers, by kind, in the client's order of preference: versions, cipher suites, curves,
+//point formats, signature schemes and application protocols. SSL 3.0 is left out, as older Go releases listed it for every
+//client without the supported_versions extension
+type helloFeatures [6][]string
+
+//greaseFeature stands for every GREASE value in the features of a ClientHello that keep them
+const greaseFeature = "GREASE"
+
+//helloInfoFeatures are the features of a ClientHello as seen by crypto/tls. GREASE values are left out, or all replaced by
+//greaseFeature if keepGREASE is set, as clients pick them at random
+func helloInfoFeatures(h *tls.ClientHelloInfo, keepGREASE bool) (f helloFeatures) {
+	versions := []uint16{}
+	for _, v := range h.SupportedVersions {
+		if v != tls.VersionSSL30 {
+			versions = append(versions, v)
+		}
+	}
+	f[0] = featureValues(versions, keepGREASE)
+	f[1] = featureValues(h.CipherSuites, keepGREASE)
+	f[2] = featureValues(curveIDs(h.SupportedCurves), keepGREASE)
+	for _, p := range h.SupportedPoints {
+		f[3] = append(f[3], strconv.Itoa(int(p)))
+	}
+	f[4] = featureValues(schemeIDs(h.SignatureSchemes), keepGREASE)
+	f[5] = append(f[5], h.SupportedProtos...)
+	return
+}
+
+//clientHelloFeatures are the features of a raw ClientHello. Without the supported_versions extension, the client supports
+//the versions from TLS 1.0 up to the one it offers
+func clientHelloFeatures(h *ClientHello, keepGREASE bool) (f helloFeatures) {
+	versions := h.SupportedVersions
+	if len(versions) == 0 {
+		for v := h.Version; v >= tls.VersionTLS10 && v <= tls.VersionTLS12; v-- {
+			versions = append(versions, v)
+		}
+	}
+	return helloInfoFeatures(&tls.ClientHelloInfo{
+		SupportedVersions: versions,
+		CipherSuites:      h.CipherSuites,
+		SupportedCurves:   asCurves(h.SupportedGroups),
+		SupportedPoints:   h.SupportedPoints,
+		SignatureSchemes:  asSchemes(h.SignatureSchemes),
+		SupportedProtos:   h.ALPNProtocols,
+	}, keepGREASE)
+}
+
+func featureValues(values []uint16, keepGREASE bool) (out []string) {
+	for _, v := range values {
+		switch {
+		case !IsGREASE(v):
+			out = append(out, strconv.Itoa(int(v)))
+		case keepGREASE:
+			out = append(out, greaseFeature)
+		}
+	}
+	return
+}
+
+func asCurves(ids []uint16) (out []tls.CurveID) {
+	for _, id := range ids {
+		out = append(out, tls.CurveID(id))
+	}
+	return
+}
+
+func asSchemes(ids []uint16) (out []tls.SignatureScheme) {
+	for _, id := range ids {
+		out = append(out, tls.SignatureScheme(id))
+	}
+	return
+}
+
+func (f helloFeatures) key() string {
+	kinds := make([]string, len(f))
+	for i, values := range f {
+		kinds[i] = strings.Join(values, "-")
+	}
+	return strings.Join(kinds, ",")
+}
+
+//similarity is the mean, over the kinds of features, of the Jaccard similarity of what two hellos offer, 1 if they offer the same
+func (f helloFeatures) similarity(o helloFeatures) float64 {
+	total := 0.0
+	for i := range f {
+		a, b := map[string]bool{}, map[string]bool{}
+		for _, v := range f[i] {
+			a[v] = true
+		}
+		for _, v := range o[i] {
+			b[v] = true
+		}
+		shared := 0
+		for v := range a {
+			if b[v] {
+				shared++
+			}
+		}
+		if union := len(a) + len(b) - shared; union == 0 {
+			total++
+		} else {
+			total += float64(shared) / float64(union)
+		}
+	}
+	return total / float64(len(f))
+}
+
+//Candidate is a browser on an operating system family that may have sent a ClientHello
+type Candidate struct {
+	ClientDescription ClientDescription //the most common description of the browser and operating system family among the matching records
+	Records           int               //the matching records of the browser and operating system family
+	Confidence        float64           //the likelihood of the candidate, from 0 to 1
+	Versions          VersionRange      //the versions of the browser recorded with the fingerprint, e.g. Chrome >= 70 <= 75
+}
+
+//Identification ranks the browsers that may have sent a ClientHello, the most likely first
+type Identification struct {
+	Exact      bool    //the fingerprint was recorded in the training data
+	Similarity float64 //the similarity of the nearest recorded fingerprints, 1 when exact
+	Records    int     //the training records with the nearest fingerprints
+	Candidates []Candidate
+}
+
+//Classifier identifies browsers from their ClientHello, trained on labelled captures such as those of GetEnrichedData.
+//A fingerprint recorded in training identifies the browsers recorded with it. Otherwise the browsers of the most similar
+//recorded fingerprints are given, with their confidence scaled by the similarity. A Classifier is safe for concurrent use
+type Classifier struct {
+	mutex   sync.RWMutex
+	groups  map[string]*helloGroup
+	records int
+}
+
+//helloGroup is the training records sharing a fingerprint
+type helloGroup struct {
+	features helloFeatures
+	records  int
+	clients  map[string]*candidateGroup //by browser and operating system family
+}
+
+//candidateGroup is the training records of a browser and operating system family sharing a fingerprint
+type candidateGroup struct {
+	records      int
+	descriptions map[ClientDescription]int
+	versions     versionSpan
+}
+
+//NewClassifier trains a classifier on the clients. Clients without a recognised browser or a ClientHello are ignored
+func NewClassifier(clients []TLSClientCapability) *Classifier {
+	c := &Classifier{groups: map[string]*helloGroup{}}
+	for _, client := range clients {
+		c.Add(client)
+	}
+	return c
+}
+
+//Add trains the classifier on another client, e.g. as it is captured
+func (c *Classifier) Add(client TLSClientCapability) {
+	d := client.ClientDescription
+	var f helloFeatures
+	switch {
+	case d.Browser == "":
+		return
+	case client.ClientHello != nil:
+		f = clientHelloFeatures(client.ClientHello, false)
+	case len(client.Capability.CipherSuites) > 0:
+		f = helloInfoFeatures(&client.Capability.ClientHelloInfo, false)
+	default:
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	g, present := c.groups[f.key()]
+	if !present {
+		g = &helloGroup{features: f, clients: map[string]*candidateGroup{}}
+		c.groups[f.key()] = g
+	}
+	family := d.OSFamily
+	if family == "" {
+		family = d.OS
+	}
+	cg, present := g.clients[d.Browser+"\x00"+family]
+	if !present {
+		cg = &candidateGroup{descriptions: map[ClientDescription]int{}}
+		g.clients[d.Browser+"\x00"+family] = cg
+	}
+	cg.records++
+	cg.descriptions[d.aggregate()]++
+	cg.versions.add(d.Browser, d.BrowserVersion)
+	g.records++
+	c.records++
+}
+
+//Learn trains the classifier on a capture, unless its fingerprint was found to contradict its user agent by DetectSpoofing.
+//Such a fingerprint would otherwise be recorded from the browser it impersonates, hiding the next impersonation
+func (c *Classifier) Learn(info TLSInfoAndAgent) bool {
+	if info.Spoofing != nil && info.Spoofing.Mismatch {
+		return false
+	}
+	c.Add(Enrich(info))
+	return true
+}
+
+//Records is the number of records the classifier was trained on
+func (c *Classifier) Records() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.records
+}
+
+//Identify ranks the browsers that may have sent a ClientHello, as seen by crypto/tls
+func (c *Classifier) Identify(hello *tls.ClientHelloInfo) Identification {
+	return c.identify(helloInfoFeatures(hello, false))
+}
+
+//IdentifyClientHello ranks the browsers that may have sent a raw ClientHello
+func (c *Classifier) IdentifyClientHello(hello *ClientHello) Identification {
+	return c.identify(clientHelloFeatures(hello, false))
+}
+
+func (c *Classifier) identify(f helloFeatures) (id Identification) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	nearest := []*helloGroup{}
+	if g, present := c.groups[f.key()]; present {
+		id.Exact, id.Similarity = true, 1
+		nearest = append(nearest, g)
+	} else {
+		for _, g := range c.groups {
+			switch s := f.similarity(g.features); {
+			case s > id.Similarity:
+				id.Similarity = s
+				nearest = []*helloGroup{g}
+			case s == id.Similarity && s > 0:
+				nearest = append(nearest, g)
+			}
+		}
+	}
+
+	merged := map[string]*candidateGroup{}
+	for _, g := range nearest {
+		id.Records += g.records
+		for k, cg := range g.clients {
+			m, present := merged[k]
+			if !present {
+				m = &candidateGroup{descriptions: map[ClientDescription]int{}}
+				merged[k] = m
+			}
+			m.records += cg.records
+			for d, n := range cg.descriptions {
+				m.descriptions[d] += n
+			}
+			m.versions.merge(cg.versions)
+		}
+	}
+	for _, m := range merged {
+		id.Candidates = append(id.Candidates, m.candidate(id.Records, id.Similarity))
+	}
+	sort.Slice(id.Candidates, func(i, j int) bool {
+		a, b := id.Candidates[i], id.Candidates[j]
+		if a.Records != b.Records {
+			return a.Records > b.Records
+		}
+		return a.ClientDescription.less(b.ClientDescription)
+	})
+	return
+}
+
+func (cg *candidateGroup) candidate(records int, similarity float64) Candidate {
+	c := Candidate{Records: cg.records, Confidence: similarity * float64(cg.records) / float64(records)}
+	for d, n := range cg.descriptions {
+		if n > cg.descriptions[c.ClientDescription] || (n == cg.descriptions[c.ClientDescription] && d.less(c.ClientDescription)) {
+			c.ClientDescription = d
+		}
+	}
+	c.Versions = VersionRange{Selectors: []VersionSelector{cg.versions.selector(c.ClientDescription.Browser)}}
+	return c
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestClassifier(t *testing.T) {
+	data, err := GetEnrichedData("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	classifier := NewClassifier(data)
+	if classifier.Records() == 0 {
+		t.Fatal("Expects the classifier to be trained on browser-data.json")
+	}
+
+	var firefox TLSClientCapability
+	for _, d := range data {
+		if d.ClientDescription.Browser == "Firefox" && d.ClientDescription.BrowserVersion == "67.0" {
+			firefox = d
+			break
+		}
+	}
+	id := classifier.Identify(&firefox.Capability.ClientHelloInfo)
+	if !id.Exact || id.Similarity != 1 || len(id.Candidates) == 0 {
+		t.Fatalf("Expects the recorded Firefox 67 fingerprint to be identified, got %+v", id)
+	}
+	total := 0.0
+	found := false
+	for _, c := range id.Candidates {
+		total += c.Confidence
+		if c.ClientDescription.Browser == "Firefox" && c.Versions.Matches(firefox.ClientDescription) {
+			found = true
+		}
+	}
+	if !found || total < 0.999 || total > 1.001 {
+		t.Errorf("Expects Firefox 67 among candidates whose confidence sums to 1, got %+v", id.Candidates)
+	}
+
+	//a raw ClientHello of the same capability has the same fingerprint
+	raw, err := BuildClientHello(firefox.Capability, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rawID := classifier.IdentifyClientHello(hello); !rawID.Exact || len(rawID.Candidates) != len(id.Candidates) {
+		t.Errorf("Expects the raw ClientHello to be identified as its capability, got %+v", rawID)
+	}
+
+	//an unrecorded fingerprint is identified by the nearest recorded ones
+	info := firefox.Capability.ClientHelloInfo
+	info.CipherSuites = info.CipherSuites[1:]
+	near := classifier.Identify(&info)
+	if near.Exact || near.Similarity >= 1 || near.Similarity < 0.9 || len(near.Candidates) == 0 || near.Candidates[0].ClientDescription.Browser != "Firefox" {
+		t.Errorf("Expects a near match to Firefox, got %+v", near)
+	}
+}
+
+func TestClassifierVersionRange(t *testing.T) {
+	info := tls.ClientHelloInfo{CipherSuites: []uint16{0x1301, 0xc02f}, SupportedVersions: []uint16{0x0a0a, tls.VersionTLS13, tls.VersionTLS12}}
+	clients := []TLSClientCapability{}
+	for _, v := range []string{"75.0.3770.100", "9.0", "70.0.3538.77"} {
+		clients = append(clients, TLSClientCapability{
+			ClientDescription: ClientDescription{Browser: "Chrome", BrowserVersion: v, OS: "Windows 10", OSFamily: "Windows"},
+			Capability:        TLSCapability{ClientHelloInfo: info},
+		})
+	}
+	clients = append(clients, TLSClientCapability{Capability: TLSCapability{ClientHelloInfo: info}}) //unrecognised, ignored
+	info.SupportedVersions = []uint16{0x1a1a, tls.VersionTLS13, tls.VersionTLS12}
+	id := NewClassifier(clients).Identify(&info)
+	if !id.Exact || id.Records != 3 || len(id.Candidates) != 1 {
+		t.Fatalf("Expects an exact match of the 3 recognised records whatever their GREASE, got %+v", id)
+	}
+	if c := id.Candidates[0]; c.Versions.String() != "Chrome >= 9.0 <= 75.0.3770.100" || c.Confidence != 1 {
+		t.Errorf("Unexpected candidate %+v", c)
+	}
+}
+
+func TestClassifierLearnSkipsSpoofed(t *testing.T) {
+	hello := goHello
+	classifier := NewClassifier(nil)
+	if !classifier.Learn(TLSInfoAndAgent{Agent: "Mozilla/5.0 (X11; Linux x86_64; rv:68.0) Gecko/20100101 Firefox/68.0", HelloInfo: &hello}) {
+		t.Fatal("Expects an unchecked capture to be learnt")
+	}
+	before := classifier.Identify(&hello)
+
+	spoofed := TLSInfoAndAgent{Agent: chrome75Agent, HelloInfo: &hello}
+	verdict := DetectSpoofing(spoofed, classifier)
+	spoofed.Spoofing = &verdict
+	if !verdict.Mismatch || classifier.Learn(spoofed) {
+		t.Fatalf("Expects the spoofed capture to be flagged and not learnt, got %+v", verdict)
+	}
+	if after := classifier.Identify(&hello); classifier.Records() != 1 || len(after.Candidates) != 1 ||
+		after.Candidates[0].ClientDescription != before.Candidates[0].ClientDescription {
+		t.Errorf("Expects the identification unchanged by the spoofed capture, got %+v", after)
+	}
+}
@@ -0,0 +1,318 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+const (
+	recordTypeHandshake      uint8 = 22
+	handshakeTypeClientHello uint8 = 1
+	handshakeTypeServerHello uint8 = 2
+	recordHeaderLength             = 5
+	maxHandshakeLength             = 1 << 17
+
+	extensionServerName              uint16 = 0
+	extensionSupportedGroups         uint16 = 10
+	extensionSupportedPoints         uint16 = 11
+	extensionSignatureAlgorithms     uint16 = 13
+	extensionALPN                    uint16 = 16
+	extensionPadding                 uint16 = 21
+	extensionSupportedVersions       uint16 = 43
+	extensionPSKKeyExchangeModes     uint16 = 45
+	extensionSignatureAlgorithmsCert uint16 = 50
+	extensionKeyShare                uint16 = 51
+)
+
+var (
+	errNotHandshake   = errors.New("Not a TLS handshake record")
+	errMalformedHello = errors.New("Malformed ClientHello")
+)
+
+//ClientHello is a TLS ClientHello as it appeared on the wire. Unlike tls.ClientHelloInfo it keeps the record layer version,
+//compression methods and every extension in the order the client sent them, including GREASE values
+type ClientHello struct {
+	Raw                  []byte //the TLS record(s) that carried the ClientHello
+	RecordVersion        uint16
+	Version              uint16
+	Random               []byte
+	SessionID            []byte
+	CipherSuites         []uint16
+	CompressionMethods   []uint8
+	Extensions           []Extension
+	ServerName           string
+	SupportedGroups      []uint16
+	SupportedPoints      []uint8
+	SignatureSchemes     []uint16
+	SignatureSchemesCert []uint16
+	ALPNProtocols        []string
+	SupportedVersions    []uint16
+	KeyShareGroups       []uint16
+	PSKKeyExchangeModes  []uint8
+	PaddingLength        int
+}
+
+//Extension is a TLS extension in its undecoded form
+type Extension struct {
+	Type uint16
+	Data []byte
+}
+
+//ExtensionTypes returns the extension types in the order they appear in the ClientHello
+func (c *ClientHello) ExtensionTypes() (out []uint16) {
+	for _, e := range c.Extensions {
+		out = append(out, e.Type)
+	}
+	return
+}
+
+//ParseClientHello decodes the ClientHello carried in the TLS record(s) at the start of data.
+//Any bytes following the ClientHello are ignored. io.ErrUnexpectedEOF is returned if data ends before the ClientHello is complete
+func ParseClientHello(data []byte) (*ClientHello, error) {
+	body, recordVersion, consumed, err := readHandshakeMessage(data, handshakeTypeClientHello)
+	if err != nil {
+		return nil, err
+	}
+	hello := ClientHello{
+		RecordVersion: recordVersion,
+		Raw:           append([]byte{}, data[:consumed]...),
+	}
+	if err := hello.unmarshal(body); err != nil {
+		return nil, err
+	}
+	return &hello, nil
+}
+
+//readHandshakeMessage reassembles the first handshake message from the TLS records at the start of data,
+//returning its body, the version of the first record and the number of bytes of data consumed
+func readHandshakeMessage(data []byte, msgType uint8) (body []byte, recordVersion uint16, consumed int, err error) {
+	handshake := []byte{}
+	for {
+		if len(data)-consumed < recordHeaderLength {
+			return nil, 0, 0, io.ErrUnexpectedEOF
+		}
+		if data[consumed] != recordTypeHandshake {
+			return nil, 0, 0, errNotHandshake
+		}
+		if consumed == 0 {
+			recordVersion = uint16(data[1])<<8 | uint16(data[2])
+		}
+		length := int(data[consumed+3])<<8 | int(data[consumed+4])
+		if len(data)-consumed-recordHeaderLength < length {
+			return nil, 0, 0, io.ErrUnexpectedEOF
+		}
+		handshake = append(handshake, data[consumed+recordHeaderLength:consumed+recordHeaderLength+length]...)
+		consumed += recordHeaderLength + length
+
+		if len(handshake) < 4 {
+			continue
+		}
+		if handshake[0] != msgType {
+			return nil, 0, 0, fmt.Errorf("Expects handshake message type %d but got %d", msgType, handshake[0])
+		}
+		msgLength := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+		if msgLength > maxHandshakeLength {
+			return nil, 0, 0, fmt.Errorf("Handshake message of %d bytes is too long", msgLength)
+		}
+		if len(handshake) >= 4+msgLength {
+			return handshake[4 : 4+msgLength], recordVersion, consumed, nil
+		}
+	}
+}
+
+func (c *ClientHello) unmarshal(body []byte) error {
+	s := cryptobyte.String(body)
+	var random, sessionID, compression []byte
+	var ciphers cryptobyte.String
+	if !s.ReadUint16(&c.Version) || !s.ReadBytes(&random, 32) ||
+		!readUint8LengthPrefixedBytes(&s, &sessionID) ||
+		!s.ReadUint16LengthPrefixed(&ciphers) ||
+		!readUint8LengthPrefixedBytes(&s, &compression) {
+		return errMalformedHello
+	}
+	c.Random = append([]byte{}, random...)
+	c.SessionID = append([]byte{}, sessionID...)
+	c.CompressionMethods = append([]uint8{}, compression...)
+	for !ciphers.Empty() {
+		var cipher uint16
+		if !ciphers.ReadUint16(&cipher) {
+			return errMalformedHello
+		}
+		c.CipherSuites = append(c.CipherSuites, cipher)
+	}
+
+	if s.Empty() {
+		//no extensions, as sent by SSL 3.0 era clients
+		return nil
+	}
+
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) || !s.Empty() {
+		return errMalformedHello
+	}
+	for !extensions.Empty() {
+		var extType uint16
+		var extData []byte
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&extType) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return errMalformedHello
+		}
+		extData = append([]byte{}, data...)
+		c.Extensions = append(c.Extensions, Extension{Type: extType, Data: extData})
+		if err := c.parseExtension(extType, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ClientHello) parseExtension(extType uint16, data cryptobyte.String) error {
+	var ok bool
+	switch extType {
+	case extensionServerName:
+		var names cryptobyte.String
+		ok = data.ReadUint16LengthPrefixed(&names)
+		for ok && !names.Empty() {
+			var nameType uint8
+			var name cryptobyte.String
+			if ok = names.ReadUint8(&nameType) && names.ReadUint16LengthPrefixed(&name); ok && nameType == 0 {
+				c.ServerName = string(name)
+			}
+		}
+	case extensionSupportedGroups:
+		c.SupportedGroups, ok = readUint16List(&data)
+	case extensionSupportedPoints:
+		var points []byte
+		if ok = readUint8LengthPrefixedBytes(&data, &points); ok {
+			c.SupportedPoints = append([]uint8{}, points...)
+		}
+	case extensionSignatureAlgorithms:
+		c.SignatureSchemes, ok = readUint16List(&data)
+	case extensionSignatureAlgorithmsCert:
+		c.SignatureSchemesCert, ok = readUint16List(&data)
+	case extensionALPN:
+		var protos cryptobyte.String
+		ok = data.ReadUint16LengthPrefixed(&protos)
+		for ok && !protos.Empty() {
+			var proto []byte
+			if ok = readUint8LengthPrefixedBytes(&protos, &proto); ok {
+				c.ALPNProtocols = append(c.ALPNProtocols, string(proto))
+			}
+		}
+	case extensionSupportedVersions:
+		var versions cryptobyte.String
+		ok = data.ReadUint8LengthPrefixed(&versions)
+		for ok && !versions.Empty() {
+			var v uint16
+			if ok = versions.ReadUint16(&v); ok {
+				c.SupportedVersions = append(c.SupportedVersions, v)
+			}
+		}
+	case extensionKeyShare:
+		var shares cryptobyte.String
+		ok = data.ReadUint16LengthPrefixed(&shares)
+		for ok && !shares.Empty() {
+			var group uint16
+			var key cryptobyte.String
+			if ok = shares.ReadUint16(&group) && shares.ReadUint16LengthPrefixed(&key); ok {
+				c.KeyShareGroups = append(c.KeyShareGroups, group)
+			}
+		}
+	case extensionPSKKeyExchangeModes:
+		var modes []byte
+		if ok = readUint8LengthPrefixedBytes(&data, &modes); ok {
+			c.PSKKeyExchangeModes = append([]uint8{}, modes...)
+		}
+	case extensionPadding:
+		c.PaddingLength = len(data)
+		return nil
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("Malformed ClientHello extension %s", hex([]uint16{extType})[0])
+	}
+	return nil
+}
+
+func readUint8LengthPrefixedBytes(s *cryptobyte.String, out *[]byte) bool {
+	var child cryptobyte.String
+	if !s.ReadUint8LengthPrefixed(&child) {
+		return false
+	}
+	*out = child
+	return true
+}
+
+func readUint16List(s *cryptobyte.String) (out []uint16, ok bool) {
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) {
+		return
+	}
+	for !list.Empty() {
+		var x uint16
+		if !list.ReadUint16(&x) {
+			return
+		}
+		out = append(out, x)
+	}
+	return out, true
+}
+
+//MarshalJSON serialises ClientHello to JSON. The raw bytes are authoritative, the decoded fields are included for readability
+func (c ClientHello) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"Raw":                  base64.StdEncoding.EncodeToString(c.Raw),
+		"RecordVersion":        hex([]uint16{c.RecordVersion})[0],
+		"Version":              hex([]uint16{c.Version})[0],
+		"CipherSuites":         hex(c.CipherSuites),
+		"CompressionMethods":   hex8(c.CompressionMethods),
+		"Extensions":           hex(c.ExtensionTypes()),
+		"ServerName":           c.ServerName,
+		"SupportedGroups":      hex(c.SupportedGroups),
+		"SupportedPoints":      hex8(c.SupportedPoints),
+		"SignatureSchemes":     hex(c.SignatureSchemes),
+		"SignatureSchemesCert": hex(c.SignatureSchemesCert),
+		"ALPNProtocols":        c.ALPNProtocols,
+		"SupportedVersions":    hex(c.SupportedVersions),
+		"KeyShareGroups":       hex(c.KeyShareGroups),
+		"PSKKeyExchangeModes":  hex8(c.PSKKeyExchangeModes),
+		"PaddingLength":        c.PaddingLength,
+	}
+	return json.Marshal(m)
+}
+
+//UnmarshalJSON deserialises ClientHello from JSON by re-parsing its raw bytes
+func (c *ClientHello) UnmarshalJSON(data []byte) error {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	hello, err := clientHelloFromMap(m)
+	if err != nil {
+		return err
+	}
+	*c = *hello
+	return nil
+}
+
+func clientHelloFromMap(v interface{}) (*ClientHello, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Expects a map but got %#v", v)
+	}
+	raw, ok := m["Raw"].(string)
+	if !ok {
+		return nil, fmt.Errorf("Expects a base64 string Raw, but got %#v", m["Raw"])
+	}
+	data, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return ParseClientHello(data)
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+)
+
+//captureGoClientHello returns the raw ClientHello sent by a Go crypto/tls client
+func captureGoClientHello(t *testing.T, conf *tls.Config) []byte {
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		tls.Client(client, conf).Handshake()
+		client.Close()
+	}()
+
+	data := []byte{}
+	buf := make([]byte, 1024)
+	for {
+		n, err := server.Read(buf)
+		data = append(data, buf[:n]...)
+		if _, e := ParseClientHello(data); e != io.ErrUnexpectedEOF {
+			return data
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseClientHello(t *testing.T) {
+	raw := captureGoClientHello(t, &tls.Config{
+		ServerName: "example.com",
+		NextProtos: []string{"h2", "http/1.1"},
+		MinVersion: tls.VersionTLS12,
+	})
+
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.ServerName != "example.com" {
+		t.Errorf("Expected server name example.com, got %q", hello.ServerName)
+	}
+	if !reflect.DeepEqual(hello.ALPNProtocols, []string{"h2", "http/1.1"}) {
+		t.Errorf("Unexpected ALPN protocols %v", hello.ALPNProtocols)
+	}
+	if hello.Version != tls.VersionTLS12 || hello.RecordVersion != tls.VersionTLS10 {
+		t.Errorf("Unexpected versions record=%#04x hello=%#04x", hello.RecordVersion, hello.Version)
+	}
+	if len(hello.SupportedVersions) == 0 || hello.SupportedVersions[0] != tls.VersionTLS13 {
+		t.Errorf("Unexpected supported versions %v", hello.SupportedVersions)
+	}
+	if len(hello.KeyShareGroups) == 0 {
+		t.Errorf("Expected key shares, got %v", hello.KeyShareGroups)
+	}
+	if !reflect.DeepEqual(hello.CompressionMethods, []uint8{0}) {
+		t.Errorf("Unexpected compression methods %v", hello.CompressionMethods)
+	}
+	if !reflect.DeepEqual(hello.Raw, raw) {
+		t.Errorf("Raw bytes not preserved")
+	}
+
+	if _, err := ParseClientHello(raw[:len(raw)-1]); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF on a truncated hello, got %v", err)
+	}
+}
+
+func TestClientHelloFragmentedRecords(t *testing.T) {
+	raw := captureGoClientHello(t, &tls.Config{ServerName: "example.com"})
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//split the handshake message across two records
+	body := raw[recordHeaderLength:]
+	split := len(body) / 2
+	fragmented := []byte{}
+	for _, part := range [][]byte{body[:split], body[split:]} {
+		fragmented = append(fragmented, recordTypeHandshake, raw[1], raw[2], byte(len(part)>>8), byte(len(part)))
+		fragmented = append(fragmented, part...)
+	}
+	hello2, err := ParseClientHello(append(fragmented, 0x17, 0x03, 0x03))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hello2.Extensions, hello.Extensions) || !reflect.DeepEqual(hello2.Raw, fragmented) {
+		t.Errorf("Fragmented ClientHello parsed differently")
+	}
+}
+
+func TestClientHelloJSONRoundTrip(t *testing.T) {
+	raw := captureGoClientHello(t, &tls.Config{ServerName: "example.com"})
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := json.Marshal(TLSInfoAndAgent{
+		Agent:       "Go",
+		HelloInfo:   &tls.ClientHelloInfo{CipherSuites: hello.CipherSuites},
+		ClientHello: hello,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := TLSInfoAndAgent{}
+	if err := json.Unmarshal(js, &info); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.ClientHello, hello) {
+		t.Errorf("ClientHello did not survive a JSON round trip")
+	}
+}
@@ -0,0 +1,267 @@
+package model
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//ClientHintHeaders are the User-Agent Client Hints a server asks for in Accept-CH to describe a browser beyond its frozen user agent
+var ClientHintHeaders = []string{
+	"Sec-CH-UA-Full-Version-List",
+	"Sec-CH-UA-Platform-Version",
+	"Sec-CH-UA-Model",
+	"Sec-CH-UA-Arch",
+	"Sec-CH-UA-Bitness",
+	"Sec-CH-UA-WoW64",
+}
+
+//brandNames maps the brands of Client Hints to browser names as recognised from user agents
+var brandNames = map[string]string{
+	"Google Chrome":    "Chrome",
+	"Microsoft Edge":   "Edge",
+	"Opera":            "Opera",
+	"Brave":            "Brave",
+	"Yandex":           "Yandex",
+	"Vivaldi":          "Vivaldi",
+	"Samsung Internet": "Samsung Internet",
+	"Chromium":         "Chromium",
+}
+
+//macOSNames are the names of macOS versions, by major version from 11 and by minor version of 10
+var macOSNames = map[string]string{
+	"10.6":  "Snow Leopard",
+	"10.7":  "Lion",
+	"10.8":  "Mountain Lion",
+	"10.9":  "Mavericks",
+	"10.10": "Yosemite",
+	"10.11": "El Capitan",
+	"10.12": "Sierra",
+	"10.13": "High Sierra",
+	"10.14": "Mojave",
+	"10.15": "Catalina",
+	"11":    "Big Sur",
+	"12":    "Monterey",
+	"13":    "Ventura",
+	"14":    "Sonoma",
+	"15":    "Sequoia",
+}
+
+//ClientHints are the User-Agent Client Hints request headers of a browser, as received
+type ClientHints struct {
+	UA              string //Sec-CH-UA, the brands and their significant versions
+	FullVersionList string //Sec-CH-UA-Full-Version-List, the brands and their full versions
+	Mobile          string //Sec-CH-UA-Mobile
+	Platform        string //Sec-CH-UA-Platform
+	PlatformVersion string //Sec-CH-UA-Platform-Version
+	Model           string //Sec-CH-UA-Model
+	Arch            string //Sec-CH-UA-Arch
+	Bitness         string //Sec-CH-UA-Bitness
+	WoW64           string //Sec-CH-UA-WoW64
+}
+
+//Brand is a browser brand and its version in a Client Hints brand list
+type Brand struct {
+	Brand   string
+	Version string
+}
+
+//NewClientHints extracts the User-Agent Client Hints of a request, nil if it has none
+func NewClientHints(header http.Header) *ClientHints {
+	h := ClientHints{
+		UA:              header.Get("Sec-CH-UA"),
+		FullVersionList: header.Get("Sec-CH-UA-Full-Version-List"),
+		Mobile:          header.Get("Sec-CH-UA-Mobile"),
+		Platform:        header.Get("Sec-CH-UA-Platform"),
+		PlatformVersion: header.Get("Sec-CH-UA-Platform-Version"),
+		Model:           header.Get("Sec-CH-UA-Model"),
+		Arch:            header.Get("Sec-CH-UA-Arch"),
+		Bitness:         header.Get("Sec-CH-UA-Bitness"),
+		WoW64:           header.Get("Sec-CH-UA-WoW64"),
+	}
+	if h == (ClientHints{}) {
+		return nil
+	}
+	return &h
+}
+
+//Brands lists the brands of the full version list, or of Sec-CH-UA without it
+func (h ClientHints) Brands() []Brand {
+	list := h.FullVersionList
+	if list == "" {
+		list = h.UA
+	}
+	brands := []Brand{}
+	for _, item := range splitStructuredList(list) {
+		params := splitOutsideQuotes(item, ';')
+		b := Brand{Brand: unquoteStructured(params[0])}
+		for _, p := range params[1:] {
+			if kv := strings.SplitN(p, "=", 2); len(kv) == 2 && strings.TrimSpace(kv[0]) == "v" {
+				b.Version = unquoteStructured(kv[1])
+			}
+		}
+		if b.Brand != "" {
+			brands = append(brands, b)
+		}
+	}
+	return brands
+}
+
+//Browser picks the browser and its version from the brands. A vendor's brand is preferred over Chromium,
+//and the made up brands browsers add to the list, such as "Not:A-Brand", are ignored
+func (h ClientHints) Browser() (name, version string, ok bool) {
+	for _, b := range h.Brands() {
+		if n, known := brandNames[b.Brand]; known && (!ok || name == "Chromium") {
+			name, version, ok = n, b.Version, true
+		}
+	}
+	return
+}
+
+//ChromiumVersion is the version of the Chromium brand, empty if the browser is not built on Chromium
+func (h ClientHints) ChromiumVersion() string {
+	for _, b := range h.Brands() {
+		if b.Brand == "Chromium" {
+			return b.Version
+		}
+	}
+	return ""
+}
+
+//OS describes the operating system from the platform and its version, in the style of the descriptions recognised from user agents
+func (h ClientHints) OS() (string, bool) {
+	platform := unquoteStructured(h.Platform)
+	version := unquoteStructured(h.PlatformVersion)
+	parts := strings.Split(version, ".")
+	major, err := strconv.Atoi(parts[0])
+	known := version != "" && err == nil
+	switch platform {
+	case "":
+		return "", false
+	case "Windows":
+		//Windows reports the version of its Universal API Contract, 13 and above being Windows 11
+		switch {
+		case !known:
+			return "Windows", true
+		case major >= 13:
+			return "Windows 11", true
+		case major > 0:
+			return "Windows 10", true
+		}
+		if len(parts) > 1 {
+			switch parts[1] {
+			case "1":
+				return "Windows 7", true
+			case "2":
+				return "Windows 8", true
+			case "3":
+				return "Windows 8.1", true
+			}
+		}
+		return "Windows", true
+	case "macOS":
+		if !known {
+			return "Mac OS X", true
+		}
+		key := parts[0]
+		if major == 10 && len(parts) > 1 {
+			key += "." + parts[1]
+		}
+		if name, ok := macOSNames[key]; ok {
+			if major == 10 {
+				return "Mac OS X (" + name + ")", true
+			}
+			return "macOS (" + name + ")", true
+		}
+		return "macOS " + key, true
+	case "Android":
+		if !known {
+			return "Android", true
+		}
+		return "Android " + parts[0], true
+	default:
+		return platform, true
+	}
+}
+
+//OSVersion is the family and version of the operating system, with Windows versions as in user agent descriptions
+func (h ClientHints) OSVersion() (family, version string) {
+	family = unquoteStructured(h.Platform)
+	version = unquoteStructured(h.PlatformVersion)
+	if family == "Windows" {
+		if os, _ := h.OS(); os != "Windows" {
+			version = strings.TrimPrefix(os, "Windows ")
+		} else {
+			version = ""
+		}
+	}
+	return
+}
+
+//Architecture is the CPU architecture in the style of user agent descriptions, empty if not hinted
+func (h ClientHints) Architecture() string {
+	if h.WoW64 == "?1" {
+		return "WOW64"
+	}
+	arch, bitness := unquoteStructured(h.Arch), unquoteStructured(h.Bitness)
+	switch {
+	case arch == "x86" && bitness == "64":
+		return "x86_64"
+	case arch == "arm" && bitness == "64":
+		return "arm64"
+	}
+	return arch
+}
+
+//DeviceClass is phone for mobile browsers, tablet for other Android browsers and desktop otherwise, empty if not hinted
+func (h ClientHints) DeviceClass() string {
+	switch {
+	case h.Mobile == "?1":
+		return DevicePhone
+	case h.Mobile != "" && unquoteStructured(h.Platform) == "Android":
+		return DeviceTablet
+	case h.Mobile != "":
+		return DeviceDesktop
+	}
+	return ""
+}
+
+//splitStructuredList splits a structured header list into its items
+func splitStructuredList(list string) (items []string) {
+	for _, item := range splitOutsideQuotes(list, ',') {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return
+}
+
+func splitOutsideQuotes(s string, sep rune) (parts []string) {
+	quoted, escaped, start := false, false, 0
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+//unquoteStructured reads a structured header string or token
+func unquoteStructured(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+const frozenChromeAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/112.0.0.0 Safari/537.36"
+
+func TestClientHints(t *testing.T) {
+	header := http.Header{}
+	header.Set("Sec-CH-UA", `"Chromium";v="112", "Microsoft Edge";v="112", "Not:A-Brand";v="99"`)
+	header.Set("Sec-CH-UA-Full-Version-List", `"Chromium";v="112.0.5615.138", "Microsoft Edge";v="112.0.1722.58", "Not:A-Brand";v="99.0.0.0"`)
+	header.Set("Sec-CH-UA-Platform", `"Windows"`)
+	header.Set("Sec-CH-UA-Platform-Version", `"15.0.0"`)
+	header.Set("Sec-CH-UA-Model", `""`)
+	header.Set("Sec-CH-UA-Mobile", "?0")
+	header.Set("Sec-CH-UA-Arch", `"arm"`)
+	header.Set("Sec-CH-UA-Bitness", `"64"`)
+	hints := NewClientHints(header)
+	if hints == nil {
+		t.Fatal("Expects Client Hints")
+	}
+	if hints.Architecture() != "arm64" || hints.DeviceClass() != DeviceDesktop {
+		t.Errorf("Unexpected architecture %s or device class %s", hints.Architecture(), hints.DeviceClass())
+	}
+	if brands := hints.Brands(); len(brands) != 3 || brands[1] != (Brand{Brand: "Microsoft Edge", Version: "112.0.1722.58"}) {
+		t.Errorf("Unexpected brands %+v", brands)
+	}
+	if name, version, ok := hints.Browser(); !ok || name != "Edge" || version != "112.0.1722.58" {
+		t.Errorf("Unexpected browser %s %s", name, version)
+	}
+
+	desc := getClientDescription(frozenChromeAgent, hints)
+	if desc.Browser != "Edge" || desc.BrowserVersion != "112.0.1722.58" || desc.ChromiumVersion != "112.0.5615.138" || desc.OS != "Windows 11" ||
+		desc.OSVersion != "11" {
+		t.Errorf("Expects the Client Hints to override the user agent, got %+v", desc)
+	}
+	if desc := getClientDescription(frozenChromeAgent, nil); desc.OS != "Windows 10" {
+		t.Errorf("Unexpected description without Client Hints %+v", desc)
+	}
+	//the low entropy hints sent without asking keep the fuller version and operating system of the user agent
+	partial := http.Header{}
+	partial.Set("Sec-CH-UA", `"Chromium";v="112", "Brave";v="112", "Not:A-Brand";v="99"`)
+	partial.Set("Sec-CH-UA-Platform", `"Windows"`)
+	partial.Set("Sec-CH-UA-Mobile", "?0")
+	if desc := getClientDescription(frozenChromeAgent, NewClientHints(partial)); desc.Browser != "Brave" || desc.BrowserVersion != "112.0" ||
+		desc.ChromiumVersion != "112.0" || desc.OS != "Windows 10" {
+		t.Errorf("Unexpected description with the low entropy Client Hints %+v", desc)
+	}
+	partial.Set("Sec-CH-UA", `"Chromium";v="113", "Google Chrome";v="113", "Not:A-Brand";v="99"`)
+	if desc := getClientDescription(frozenChromeAgent, NewClientHints(partial)); desc.Browser != "Chrome" || desc.BrowserVersion != "113" ||
+		desc.ChromiumVersion != "113" {
+		t.Errorf("Expects the significant version of the Client Hints over a different user agent version, got %+v", desc)
+	}
+	if NewClientHints(http.Header{}) != nil {
+		t.Error("Expects no Client Hints without the headers")
+	}
+
+	info := TLSInfoAndAgent{Agent: frozenChromeAgent, HelloInfo: &tls.ClientHelloInfo{}, ClientHints: hints}
+	js, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info2 := TLSInfoAndAgent{}
+	if err := json.Unmarshal(js, &info2); err != nil || info2.ClientHints == nil || *info2.ClientHints != *hints {
+		t.Errorf("Client Hints did not round trip %s %v", js, err)
+	}
+}
+
+func TestClientHintsOS(t *testing.T) {
+	platforms := map[[2]string]string{
+		{`"Windows"`, `"10.0.0"`}: "Windows 10",
+		{`"Windows"`, `"0.3.0"`}:  "Windows 8.1",
+		{`"Windows"`, ``}:         "Windows",
+		{`"macOS"`, `"10.14.6"`}:  "Mac OS X (Mojave)",
+		{`"macOS"`, `"13.4.1"`}:   "macOS (Ventura)",
+		{`"Android"`, `"13.0.0"`}: "Android 13",
+		{`"Linux"`, `""`}:         "Linux",
+	}
+	for p, expected := range platforms {
+		if os, ok := (ClientHints{Platform: p[0], PlatformVersion: p[1]}).OS(); !ok || os != expected {
+			t.Errorf("Expects %s for %v, got %s", expected, p, os)
+		}
+	}
+	if _, ok := (ClientHints{}).OS(); ok {
+		t.Error("Expects no OS without a platform")
+	}
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"sort"
+)
+
+//CanonicalFingerprints identify the TLS stack of a client whatever GREASE values it picked. They are MD5 hashes of the versions,
+//cipher suites, curves, point formats, signature schemes and application protocols offered, with every GREASE value replaced
+//by one placeholder, and are computed alike from the raw ClientHello or from what crypto/tls saw of it
+type CanonicalFingerprints struct {
+	Ordered   string //the features in the client's order of preference
+	Unordered string //the features sorted, shared by clients offering the same in a different order
+}
+
+//Canonical computes the canonical fingerprints of a client, empty if it has no ClientHello
+func Canonical(client TLSClientCapability) (fp CanonicalFingerprints) {
+	f, ok := canonicalFeatures(client)
+	if !ok {
+		return
+	}
+	fp.Ordered = md5Hex(f.key())
+	for i := range f {
+		f[i] = append([]string{}, f[i]...)
+		sort.Strings(f[i])
+	}
+	fp.Unordered = md5Hex(f.key())
+	return
+}
+
+func canonicalFeatures(client TLSClientCapability) (helloFeatures, bool) {
+	switch {
+	case client.ClientHello != nil:
+		return clientHelloFeatures(client.ClientHello, true), true
+	case len(client.Capability.CipherSuites) > 0:
+		return helloInfoFeatures(&client.Capability.ClientHelloInfo, true), true
+	}
+	return helloFeatures{}, false
+}
+
+//AgentCount is the number of records of a user agent
+type AgentCount struct {
+	Agent   string
+	Records int
+}
+
+//FingerprintCluster is the records sharing a canonical fingerprint, a distinct TLS stack
+type FingerprintCluster struct {
+	Fingerprint string        //the ordered or unordered canonical fingerprint
+	Records     int           //the records with the fingerprint
+	Agents      []AgentCount  //the user agents of the records, the most frequent first
+	Versions    VersionRange  //the recognised browsers and their versions, e.g. Chrome >= 70 <= 75, Opera >= 57 <= 62
+	Capability  TLSCapability //the capability of the first record
+}
+
+//ClusterFingerprints groups the clients by their ordered or unordered canonical fingerprint, the largest cluster first.
+//Clients without a ClientHello are left out
+func ClusterFingerprints(clients []TLSClientCapability, ordered bool) (out []FingerprintCluster) {
+	type cluster struct {
+		FingerprintCluster
+		agents   map[string]int
+		browsers map[string]*versionSpan
+	}
+	clusters := map[string]*cluster{}
+	order := []*cluster{}
+	for _, c := range clients {
+		fp := Canonical(c)
+		key := fp.Unordered
+		if ordered {
+			key = fp.Ordered
+		}
+		if key == "" {
+			continue
+		}
+		cl, present := clusters[key]
+		if !present {
+			cl = &cluster{
+				FingerprintCluster: FingerprintCluster{Fingerprint: key, Capability: c.Capability},
+				agents:             map[string]int{},
+				browsers:           map[string]*versionSpan{},
+			}
+			clusters[key] = cl
+			order = append(order, cl)
+		}
+		cl.Records++
+		cl.agents[c.Agent]++
+		if d := c.ClientDescription; d.Browser != "" {
+			span, present := cl.browsers[d.Browser]
+			if !present {
+				span = &versionSpan{}
+				cl.browsers[d.Browser] = span
+			}
+			span.add(d.Browser, d.BrowserVersion)
+		}
+	}
+
+	for _, cl := range order {
+		for agent, records := range cl.agents {
+			cl.Agents = append(cl.Agents, AgentCount{Agent: agent, Records: records})
+		}
+		sort.Slice(cl.Agents, func(i, j int) bool {
+			if cl.Agents[i].Records != cl.Agents[j].Records {
+				return cl.Agents[i].Records > cl.Agents[j].Records
+			}
+			return cl.Agents[i].Agent < cl.Agents[j].Agent
+		})
+		browsers := []string{}
+		for b := range cl.browsers {
+			browsers = append(browsers, b)
+		}
+		sort.Strings(browsers)
+		for _, b := range browsers {
+			cl.Versions.Selectors = append(cl.Versions.Selectors, cl.browsers[b].selector(b))
+		}
+		out = append(out, cl.FingerprintCluster)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Records > out[j].Records })
+	return
+}
+
+//Deduplicate keeps the first record of each user agent and ordered canonical fingerprint, so that the repeated visits of a
+//browser count once. Clients without a ClientHello are kept
+func Deduplicate(clients []TLSClientCapability) (out []TLSClientCapability) {
+	seen := map[[2]string]bool{}
+	for _, c := range clients {
+		if fp := Canonical(c).Ordered; fp != "" {
+			key := [2]string{c.Agent, fp}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		out = append(out, c)
+	}
+	return
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func testClient(browser, version, agent string, info tls.ClientHelloInfo) TLSClientCapability {
+	return TLSClientCapability{
+		ClientDescription: ClientDescription{Browser: browser, BrowserVersion: version},
+		Agent:             agent,
+		Capability:        TLSCapability{ClientHelloInfo: info},
+	}
+}
+
+func TestCanonicalFingerprints(t *testing.T) {
+	chrome := tls.ClientHelloInfo{
+		CipherSuites:      []uint16{0x0a0a, 0x1301, 0xc02f},
+		SupportedCurves:   []tls.CurveID{0x2a2a, tls.X25519},
+		SupportedVersions: []uint16{0x3a3a, tls.VersionTLS13, tls.VersionTLS12},
+	}
+	regreased := chrome
+	regreased.CipherSuites = []uint16{0xdada, 0x1301, 0xc02f}
+	reordered := chrome
+	reordered.CipherSuites = []uint16{0x0a0a, 0xc02f, 0x1301}
+	greaseless := chrome
+	greaseless.CipherSuites = []uint16{0x1301, 0xc02f}
+
+	fp := Canonical(testClient("Chrome", "75.0", "a", chrome))
+	if fp.Ordered == "" || fp.Ordered == fp.Unordered {
+		t.Fatalf("Unexpected fingerprints %+v", fp)
+	}
+	if Canonical(testClient("Chrome", "75.0", "a", regreased)) != fp {
+		t.Error("Expects the fingerprints to ignore the GREASE values picked")
+	}
+	if r := Canonical(testClient("Chrome", "75.0", "a", reordered)); r.Ordered == fp.Ordered || r.Unordered != fp.Unordered {
+		t.Errorf("Expects only the ordered fingerprint to change with the order, got %+v and %+v", fp, r)
+	}
+	if Canonical(testClient("Chrome", "75.0", "a", greaseless)).Unordered == fp.Unordered {
+		t.Error("Expects the presence of GREASE to change the fingerprints")
+	}
+	if (Canonical(TLSClientCapability{}) != CanonicalFingerprints{}) {
+		t.Error("Expects no fingerprints without a ClientHello")
+	}
+
+	//the raw ClientHello of a capability has its fingerprints
+	legacy := tls.ClientHelloInfo{CipherSuites: []uint16{0xc013, 0x002f}, SupportedVersions: []uint16{0x0303, 0x0302, 0x0301, 0x0300}}
+	raw, err := BuildClientHello(TLSCapability{ClientHelloInfo: legacy}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Canonical(TLSClientCapability{ClientHello: hello}) != Canonical(testClient("", "", "", legacy)) {
+		t.Error("Expects a raw ClientHello to have the fingerprints of its capability")
+	}
+}
+
+func TestClusterFingerprints(t *testing.T) {
+	a := tls.ClientHelloInfo{CipherSuites: []uint16{0x1301, 0xc02f}, SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12}}
+	b := a
+	b.CipherSuites = []uint16{0xc02f, 0x1301}
+	clients := []TLSClientCapability{
+		testClient("Chrome", "9.0", "chrome9", a),
+		testClient("Chrome", "10.0", "chrome10", a),
+		testClient("Chrome", "10.0", "chrome10", a),
+		testClient("Opera", "62.0.3331.72", "opera", a),
+		testClient("", "", "bot", b),
+		{Agent: "no hello"},
+	}
+	ordered := ClusterFingerprints(clients, true)
+	if len(ordered) != 2 || ordered[0].Records != 4 || ordered[1].Records != 1 {
+		t.Fatalf("Unexpected clusters %+v", ordered)
+	}
+	c := ordered[0]
+	if c.Versions.String() != "Chrome >= 9.0 <= 10.0, Opera = 62.0.3331.72" || len(c.Agents) != 3 || c.Agents[0] != (AgentCount{"chrome10", 2}) {
+		t.Errorf("Unexpected cluster %+v", c)
+	}
+	if unordered := ClusterFingerprints(clients, false); len(unordered) != 1 || unordered[0].Records != 5 {
+		t.Errorf("Expects a single unordered cluster, got %+v", unordered)
+	}
+
+	if dedup := Deduplicate(clients); len(dedup) != 5 || dedup[2].Agent != "opera" || dedup[4].Agent != "no hello" {
+		t.Errorf("Expects the repeated Chrome 10 visit to be removed, got %+v", dedup)
+	}
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+//ConnectionID identifies a single client connection for the lifetime of a service
+type ConnectionID uint64
+
+//CorrelatorStats are counters describing how ClientHellos and HTTP requests were matched up
+type CorrelatorStats struct {
+	Hellos            uint64 //ClientHellos recorded
+	Requests          uint64 //requests that looked up a ClientHello
+	Matched           uint64 //requests matched to a ClientHello
+	UnmatchedRequests uint64 //requests for which no ClientHello was held
+	UnmatchedHellos   uint64 //ClientHellos dropped without ever being matched to a request
+	Expired           uint64 //ClientHellos dropped because they outlived the TTL
+	Evicted           uint64 //ClientHellos dropped to keep within the size cap
+	Pending           int    //ClientHellos currently held
+}
+
+//Correlator joins the ClientHello of a connection to the HTTP requests later made on that connection.
+//Entries expire after a TTL and the number held is capped, the oldest being evicted first. It is safe for concurrent use
+type Correlator struct {
+	ttl     time.Duration
+	maxSize int
+	now     func() time.Time
+	mutex   sync.Mutex
+	entries map[ConnectionID]*list.Element
+	order   *list.List //oldest entry at the front
+	stats   CorrelatorStats
+}
+
+type correlatorEntry struct {
+	id      ConnectionID
+	info    TLSInfoAndAgent
+	added   time.Time
+	matched bool
+}
+
+//NewCorrelator creates a Correlator that holds ClientHellos for ttl and no more than maxSize at a time
+func NewCorrelator(ttl time.Duration, maxSize int) *Correlator {
+	return &Correlator{
+		ttl:     ttl,
+		maxSize: maxSize,
+		now:     time.Now,
+		entries: make(map[ConnectionID]*list.Element),
+		order:   list.New(),
+	}
+}
+
+//AddHello records the ClientHello seen on a connection
+func (c *Correlator) AddHello(id ConnectionID, info TLSInfoAndAgent) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := c.now()
+	c.expire(now)
+	c.stats.Hellos++
+	if e, present := c.entries[id]; present {
+		c.drop(e)
+	}
+	for c.maxSize > 0 && c.order.Len() >= c.maxSize {
+		c.stats.Evicted++
+		c.drop(c.order.Front())
+	}
+	c.entries[id] = c.order.PushBack(&correlatorEntry{
+		id:    id,
+		info:  info,
+		added: now,
+	})
+}
+
+//Match returns the ClientHello recorded for a connection. The entry is kept, as a connection may carry several requests
+func (c *Correlator) Match(id ConnectionID) (TLSInfoAndAgent, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expire(c.now())
+	c.stats.Requests++
+	e, present := c.entries[id]
+	if !present {
+		c.stats.UnmatchedRequests++
+		return TLSInfoAndAgent{}, false
+	}
+	entry := e.Value.(*correlatorEntry)
+	entry.matched = true
+	c.stats.Matched++
+	return entry.info, true
+}
+
+//Stats returns a snapshot of the correlation counters
+func (c *Correlator) Stats() CorrelatorStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expire(c.now())
+	stats := c.stats
+	stats.Pending = c.order.Len()
+	return stats
+}
+
+func (c *Correlator) expire(now time.Time) {
+	for e := c.order.Front(); e != nil && now.Sub(e.Value.(*correlatorEntry).added) >= c.ttl; e = c.order.Front() {
+		c.stats.Expired++
+		c.drop(e)
+	}
+}
+
+func (c *Correlator) drop(e *list.Element) {
+	entry := c.order.Remove(e).(*correlatorEntry)
+	delete(c.entries, entry.id)
+	if !entry.matched {
+		c.stats.UnmatchedHellos++
+	}
+}
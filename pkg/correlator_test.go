@@ -0,0 +1,83 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestCorrelator(ttl time.Duration, size int) (*Correlator, *time.Time) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCorrelator(ttl, size)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func TestCorrelatorMatch(t *testing.T) {
+	c, _ := newTestCorrelator(time.Minute, 10)
+	c.AddHello(1, TLSInfoAndAgent{Agent: "one"})
+	c.AddHello(2, TLSInfoAndAgent{Agent: "two"})
+
+	for i := 0; i < 2; i++ {
+		//a connection may carry several requests
+		if info, ok := c.Match(2); !ok || info.Agent != "two" {
+			t.Errorf("Expected to match connection 2, got %#v", info)
+		}
+	}
+	if _, ok := c.Match(3); ok {
+		t.Errorf("Connection 3 has no hello")
+	}
+
+	stats := c.Stats()
+	if stats.Hellos != 2 || stats.Requests != 3 || stats.Matched != 2 || stats.UnmatchedRequests != 1 || stats.Pending != 2 {
+		t.Errorf("Unexpected stats %#v", stats)
+	}
+}
+
+func TestCorrelatorExpiry(t *testing.T) {
+	c, now := newTestCorrelator(time.Minute, 10)
+	c.AddHello(1, TLSInfoAndAgent{})
+	*now = now.Add(30 * time.Second)
+	c.AddHello(2, TLSInfoAndAgent{})
+	c.Match(2)
+	*now = now.Add(30 * time.Second)
+
+	if _, ok := c.Match(1); ok {
+		t.Errorf("Connection 1 should have expired")
+	}
+	if _, ok := c.Match(2); !ok {
+		t.Errorf("Connection 2 should not have expired")
+	}
+	*now = now.Add(time.Minute)
+	stats := c.Stats()
+	if stats.Expired != 2 || stats.UnmatchedHellos != 1 || stats.Pending != 0 {
+		t.Errorf("Unexpected stats %#v", stats)
+	}
+}
+
+func TestCorrelatorSizeCap(t *testing.T) {
+	c, _ := newTestCorrelator(time.Hour, 3)
+	for id := ConnectionID(1); id <= 5; id++ {
+		c.AddHello(id, TLSInfoAndAgent{})
+	}
+	for id, want := range map[ConnectionID]bool{1: false, 2: false, 3: true, 4: true, 5: true} {
+		if _, ok := c.Match(id); ok != want {
+			t.Errorf("Connection %d: expected match %v", id, want)
+		}
+	}
+	stats := c.Stats()
+	if stats.Evicted != 2 || stats.UnmatchedHellos != 2 || stats.Pending != 3 {
+		t.Errorf("Unexpected stats %#v", stats)
+	}
+}
+
+func TestCorrelatorReplacesHello(t *testing.T) {
+	c, _ := newTestCorrelator(time.Hour, 3)
+	c.AddHello(1, TLSInfoAndAgent{Agent: "old"})
+	c.AddHello(1, TLSInfoAndAgent{Agent: "new"})
+	if info, _ := c.Match(1); info.Agent != "new" {
+		t.Errorf("Expected the latest hello, got %#v", info)
+	}
+	if stats := c.Stats(); stats.Pending != 1 {
+		t.Errorf("Unexpected stats %#v", stats)
+	}
+}
@@ -0,0 +1,176 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+//CapabilityChange is a user agent recorded in two snapshots with different TLS capabilities
+type CapabilityChange struct {
+	Agent             string
+	ClientDescription ClientDescription //as described in the newer snapshot
+	Before            []string          //the ordered canonical fingerprints of the agent in the older snapshot
+	After             []string          //the ordered canonical fingerprints of the agent in the newer snapshot
+	Added             []string          //the features offered only in the newer snapshot, named kind:name as in the wide CSV export
+	Removed           []string          //the features offered only in the older snapshot
+}
+
+//FeatureShift is the change in the share of records offering a feature
+type FeatureShift struct {
+	Feature string  //one of the Feature kinds
+	Value   string  //the name of the feature
+	Before  float64 //the fraction of the records of the older snapshot offering the feature
+	After   float64 //the fraction of the records of the newer snapshot offering the feature
+}
+
+//Change is the difference of the shares, in the range -1 to 1
+func (s FeatureShift) Change() float64 {
+	return s.After - s.Before
+}
+
+//SnapshotDiff compares two snapshots of the enriched data
+type SnapshotDiff struct {
+	Before, After               time.Time //the timestamps of the snapshots
+	BeforeRecords, AfterRecords int
+	NewClients                  []ClientCount      //browsers, versions and operating systems recorded only in the newer snapshot
+	RemovedClients              []ClientCount      //browsers, versions and operating systems recorded only in the older snapshot
+	Changed                     []CapabilityChange //the user agents whose capabilities changed, by agent
+	Shifts                      []FeatureShift     //the features whose share of records changed, the largest change first
+}
+
+//DiffCapabilities compares an older snapshot of the enriched data to a newer one
+func DiffCapabilities(before, after TLSCapabilities) (diff SnapshotDiff) {
+	diff.Before, diff.After = before.Timestamp, after.Timestamp
+	diff.BeforeRecords, diff.AfterRecords = len(before.Capabilities), len(after.Capabilities)
+
+	type snapshot struct {
+		clients      map[ClientDescription]int
+		agents       map[string]*agentCapabilities
+		features     map[string]int //the records offering each feature
+		featureNames map[string]feature
+	}
+	summarise := func(caps []TLSClientCapability) snapshot {
+		s := snapshot{clients: map[ClientDescription]int{}, agents: map[string]*agentCapabilities{}, features: map[string]int{},
+			featureNames: map[string]feature{}}
+		for _, c := range caps {
+			s.clients[snapshotDescription(c)]++
+			a, present := s.agents[c.Agent]
+			if !present {
+				a = &agentCapabilities{fingerprints: map[string]bool{}, features: map[string]bool{}}
+				s.agents[c.Agent] = a
+			}
+			a.description = c.ClientDescription
+			if fp := Canonical(c).Ordered; fp != "" {
+				a.fingerprints[fp] = true
+			}
+			offered := map[string]bool{}
+			for _, f := range features(c.Capability) {
+				a.features[f.column()] = true
+				s.featureNames[f.column()] = f
+				offered[f.column()] = true
+			}
+			for column := range offered {
+				s.features[column]++
+			}
+		}
+		return s
+	}
+	older, newer := summarise(before.Capabilities), summarise(after.Capabilities)
+
+	added, removed := map[ClientDescription]int{}, map[ClientDescription]int{}
+	for d, n := range newer.clients {
+		if _, present := older.clients[d]; !present {
+			added[d] = n
+		}
+	}
+	for d, n := range older.clients {
+		if _, present := newer.clients[d]; !present {
+			removed[d] = n
+		}
+	}
+	diff.NewClients, diff.RemovedClients = sortedCounts(added), sortedCounts(removed)
+
+	for agent, a := range newer.agents {
+		b, present := older.agents[agent]
+		if !present || sameKeys(a.fingerprints, b.fingerprints) {
+			continue
+		}
+		diff.Changed = append(diff.Changed, CapabilityChange{
+			Agent:             agent,
+			ClientDescription: a.description,
+			Before:            sortedKeys(b.fingerprints),
+			After:             sortedKeys(a.fingerprints),
+			Added:             missingKeys(a.features, b.features),
+			Removed:           missingKeys(b.features, a.features),
+		})
+	}
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Agent < diff.Changed[j].Agent })
+
+	all := map[string]feature{}
+	for _, names := range []map[string]feature{older.featureNames, newer.featureNames} {
+		for column, f := range names {
+			all[column] = f
+		}
+	}
+	for column, f := range all {
+		s := FeatureShift{Feature: f.kind, Value: f.value, Before: share(older.features[column], diff.BeforeRecords),
+			After: share(newer.features[column], diff.AfterRecords)}
+		if s.Change() != 0 {
+			diff.Shifts = append(diff.Shifts, s)
+		}
+	}
+	sort.Slice(diff.Shifts, func(i, j int) bool {
+		a, b := diff.Shifts[i], diff.Shifts[j]
+		if ca, cb := math.Abs(a.Change()), math.Abs(b.Change()); ca != cb {
+			return ca > cb
+		}
+		if a.Feature != b.Feature {
+			return a.Feature < b.Feature
+		}
+		return a.Value < b.Value
+	})
+	return
+}
+
+//snapshotDescription describes a record by its user agent with the current rules, so that a snapshot enriched by older rules,
+//without the fields added since, compares alike. A record whose agent is not recognised keeps the description it was stored with
+func snapshotDescription(c TLSClientCapability) ClientDescription {
+	if d, err := ParseClientDescription(c.Agent); err == nil {
+		return d.aggregate()
+	}
+	return c.ClientDescription.aggregate()
+}
+
+//agentCapabilities is what the records of a user agent offer
+type agentCapabilities struct {
+	description  ClientDescription
+	fingerprints map[string]bool
+	features     map[string]bool
+}
+
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func sameKeys(a, b map[string]bool) bool {
+	return len(a) == len(b) && len(missingKeys(a, b)) == 0
+}
+
+//missingKeys lists, sorted, the keys of a that are not in b
+func missingKeys(a, b map[string]bool) (out []string) {
+	for k := range a {
+		if !b[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out)
+	return
+}
+
+func sortedKeys(m map[string]bool) []string {
+	return missingKeys(m, nil)
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiffCapabilities(t *testing.T) {
+	client := func(agent, browser, version string, versions ...uint16) TLSClientCapability {
+		return TLSClientCapability{
+			Agent:             agent,
+			ClientDescription: ClientDescription{Browser: browser, BrowserVersion: version, OS: "Windows 10"},
+			Capability:        TLSCapability{ClientHelloInfo: tls.ClientHelloInfo{CipherSuites: []uint16{0xc02f}, SupportedVersions: versions}},
+		}
+	}
+	before := TLSCapabilities{Timestamp: time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC), Capabilities: []TLSClientCapability{
+		client("firefox", "Firefox", "67.0", tls.VersionTLS12, tls.VersionTLS10),
+		client("firefox", "Firefox", "67.0", tls.VersionTLS12, tls.VersionTLS10),
+		client("edge", "Edge", "18.0", tls.VersionTLS12, tls.VersionTLS10),
+		client("ie", "IE", "11.0", tls.VersionTLS12, tls.VersionTLS10),
+	}}
+	after := TLSCapabilities{Timestamp: time.Date(2019, 7, 1, 0, 0, 0, 0, time.UTC), Capabilities: []TLSClientCapability{
+		client("firefox", "Firefox", "67.0", tls.VersionTLS13, tls.VersionTLS12),
+		client("edge", "Edge", "18.0", tls.VersionTLS12, tls.VersionTLS10),
+		client("chrome", "Chrome", "75.0", tls.VersionTLS13, tls.VersionTLS12),
+		client("chrome", "Chrome", "75.0", tls.VersionTLS13, tls.VersionTLS12),
+		client("chrome", "Chrome", "75.0", tls.VersionTLS13, tls.VersionTLS12),
+	}}
+
+	d := DiffCapabilities(before, after)
+	if !d.Before.Equal(before.Timestamp) || !d.After.Equal(after.Timestamp) || d.BeforeRecords != 4 || d.AfterRecords != 5 {
+		t.Errorf("Unexpected snapshots %+v", d)
+	}
+	if len(d.NewClients) != 1 || d.NewClients[0].ClientDescription.Browser != "Chrome" || d.NewClients[0].Records != 3 {
+		t.Errorf("Expects Chrome as a new client, got %+v", d.NewClients)
+	}
+	if len(d.RemovedClients) != 1 || d.RemovedClients[0].ClientDescription.Browser != "IE" {
+		t.Errorf("Expects IE as a removed client, got %+v", d.RemovedClients)
+	}
+	if len(d.Changed) != 1 {
+		t.Fatalf("Expects only the capabilities of Firefox to change, got %+v", d.Changed)
+	}
+	firefox := d.Changed[0]
+	if firefox.Agent != "firefox" || len(firefox.Before) != 1 || len(firefox.After) != 1 || firefox.Before[0] == firefox.After[0] {
+		t.Errorf("Unexpected change %+v", firefox)
+	}
+	if len(firefox.Added) != 1 || firefox.Added[0] != "version:TLS v1.3" || len(firefox.Removed) != 1 || firefox.Removed[0] != "version:TLS v1.0" {
+		t.Errorf("Expects TLS 1.3 added and TLS 1.0 removed, got %v and %v", firefox.Added, firefox.Removed)
+	}
+
+	//TLS 1.0 from 100% to 20%, TLS 1.3 from 0% to 80%, TLS 1.2 and the cipher suite unchanged
+	if len(d.Shifts) != 2 {
+		t.Fatalf("Expects shifts of TLS 1.0 and TLS 1.3, got %+v", d.Shifts)
+	}
+	tls10, tls13 := d.Shifts[0], d.Shifts[1]
+	if tls10.Feature != FeatureVersion || tls10.Value != "TLS v1.0" || tls10.Before != 1 || tls10.After != 0.2 {
+		t.Errorf("Unexpected shift %+v", tls10)
+	}
+	if tls13.Value != "TLS v1.3" || tls13.Before != 0 || tls13.After != 0.8 || tls13.Change() != 0.8 {
+		t.Errorf("Unexpected shift %+v", tls13)
+	}
+}
+
+func TestDiffCapabilitiesOlderRules(t *testing.T) {
+	agent := "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1.1 Safari/605.1.15"
+	current, err := ParseClientDescription(agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capability := TLSCapability{ClientHelloInfo: tls.ClientHelloInfo{CipherSuites: []uint16{0xc02f}, SupportedVersions: []uint16{tls.VersionTLS12}}}
+	//a backup enriched before the engine and the other details were described, and with Safari's WebKit build as its version
+	before := TLSCapabilities{Capabilities: []TLSClientCapability{{Agent: agent, Capability: capability,
+		ClientDescription: ClientDescription{Browser: "Safari", BrowserVersion: "605.1.15", OS: "Mac OS X (Mojave)"}}}}
+	after := TLSCapabilities{Capabilities: []TLSClientCapability{{Agent: agent, Capability: capability, ClientDescription: current}}}
+	if d := DiffCapabilities(before, after); len(d.NewClients) != 0 || len(d.RemovedClients) != 0 {
+		t.Errorf("Expects the same client in both snapshots, got %+v new and %+v removed", d.NewClients, d.RemovedClients)
+	}
+}
+
+func TestLoadCapabilities(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "enriched-browser-data.json")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	w, err := NewCapabilitiesWriter(out, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capability := TLSCapability{
+		ClientHelloInfo:      tls.ClientHelloInfo{CipherSuites: []uint16{0xc02f}, SignatureSchemes: []tls.SignatureScheme{tls.PSSWithSHA256}},
+		CipherSuiteNames:     []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		SignatureSchemeNames: []string{"rsa_pss_rsae_sha256"},
+	}
+	if err := w.Write(TLSClientCapability{Agent: "agent", Capability: capability}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	out.Close()
+
+	caps, err := LoadCapabilities(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Timestamp.Equal(now) || len(caps.Capabilities) != 1 {
+		t.Fatalf("Unexpected document %+v", caps)
+	}
+	got := caps.Capabilities[0].Capability
+	if len(got.CipherSuiteNames) != 1 || got.CipherSuiteNames[0] != capability.CipherSuiteNames[0] ||
+		len(got.SignatureSchemeNames) != 1 || got.SignatureSchemeNames[0] != capability.SignatureSchemeNames[0] {
+		t.Errorf("Expects the names of the cipher suites and signature schemes to be read back, got %+v", got)
+	}
+	if _, err := LoadCapabilities(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expects an error for a missing file")
+	}
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"fmt"
+)
+
+//DataNotFoundError is returned when the browser TLS audit data file does not exist
+type DataNotFoundError struct {
+	Path string
+	Err  error
+}
+
+func (e *DataNotFoundError) Error() string {
+	return fmt.Sprintf("Browser TLS audit data not found at %s", e.Path)
+}
+
+//Unwrap returns the underlying file system error
+func (e *DataNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+//UnknownAgentError is returned when the browser or operating system cannot be recognised in a user agent
+type UnknownAgentError struct {
+	Agent string
+	Part  string //what could not be recognised: "OS" or "browser"
+}
+
+func (e *UnknownAgentError) Error() string {
+	return fmt.Sprintf("Unknown %s from user agent: %s", e.Part, e.Agent)
+}
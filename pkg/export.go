@@ -0,0 +1,341 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+
+	tlsdefs "github.com/adedayo/tls-definitions"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+//Kinds of the features of a TLS capability in the exports, in the order of their columns in the wide CSV
+const (
+	FeatureVersion         = "version"
+	FeatureCipherSuite     = "cipher"
+	FeatureCurve           = "curve"
+	FeaturePointFormat     = "point"
+	FeatureSignatureScheme = "scheme"
+	FeatureALPN            = "alpn"
+)
+
+var featureKinds = []string{FeatureVersion, FeatureCipherSuite, FeatureCurve, FeaturePointFormat, FeatureSignatureScheme, FeatureALPN}
+
+//pointFormats are the names of the EC point formats
+var pointFormats = map[uint8]string{
+	0: "uncompressed",
+	1: "ansiX962_compressed_prime",
+	2: "ansiX962_compressed_char2",
+}
+
+//recordColumns are the columns describing a record that lead both CSV exports
+var recordColumns = []string{"record", "browser", "browser_version", "os", "ja3_hash", "ja4", "grade", "score"}
+
+//FeatureRow is a row of the long-format export: a feature offered by a record, one of its versions, cipher suites, curves,
+//point formats, signature schemes or application protocols. GREASE values are left out
+type FeatureRow struct {
+	Record         int64  `parquet:"name=record, type=INT64"`
+	Browser        string `parquet:"name=browser, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	BrowserVersion string `parquet:"name=browser_version, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	OS             string `parquet:"name=os, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	JA3Hash        string `parquet:"name=ja3_hash, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	JA4            string `parquet:"name=ja4, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Grade          string `parquet:"name=grade, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Score          int32  `parquet:"name=score, type=INT32"`
+	Feature        string `parquet:"name=feature, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"` //one of the Feature kinds
+	Code           string `parquet:"name=code, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`    //the hex code point, empty for application protocols
+	Value          string `parquet:"name=value, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`   //the name of the feature
+	Position       int32  `parquet:"name=position, type=INT32"`                                                    //the position in the client's order of preference for the kind of feature, from 0
+}
+
+//feature is a version, cipher suite, curve, point format, signature scheme or application protocol offered by a client
+type feature struct {
+	kind, code, value string
+}
+
+//column is the name of the feature's column in the wide CSV
+func (f feature) column() string {
+	return f.kind + ":" + f.value
+}
+
+//features lists what a capability offers, by kind and then in the client's order of preference
+func features(cap TLSCapability) (out []feature) {
+	for _, v := range cap.SupportedVersions {
+		if !IsGREASE(v) {
+			out = append(out, feature{FeatureVersion, hex([]uint16{v})[0], versionName(v)})
+		}
+	}
+	for _, cs := range cap.CipherSuites {
+		if !IsGREASE(cs) {
+			out = append(out, feature{FeatureCipherSuite, hex([]uint16{cs})[0], cipherSuiteName(cs)})
+		}
+	}
+	for _, c := range cap.SupportedCurves {
+		if !IsGREASE(uint16(c)) {
+			out = append(out, feature{FeatureCurve, hexCurve([]tls.CurveID{c})[0], curveName(c)})
+		}
+	}
+	for _, p := range cap.SupportedPoints {
+		name, ok := pointFormats[p]
+		if !ok {
+			name = hex8([]uint8{p})[0]
+		}
+		out = append(out, feature{FeaturePointFormat, hex8([]uint8{p})[0], name})
+	}
+	for _, s := range cap.SignatureSchemes {
+		if !IsGREASE(uint16(s)) {
+			out = append(out, feature{FeatureSignatureScheme, hexSignature([]tls.SignatureScheme{s})[0], names(tlsdefs.SignatureSchemes, []uint16{uint16(s)})[0]})
+		}
+	}
+	for _, p := range cap.SupportedProtos {
+		out = append(out, feature{FeatureALPN, "", p})
+	}
+	return
+}
+
+//CapabilityExporter writes capabilities one at a time, as they are read, so that an export needs no more than a record in memory.
+//Close completes the export, without closing the underlying writer
+type CapabilityExporter interface {
+	Write(capability TLSClientCapability) error
+	Close() error
+}
+
+//LongRows flattens the capabilities into one row per record and feature. Records are numbered by their index in caps
+func LongRows(caps []TLSClientCapability) (rows []FeatureRow) {
+	for i, c := range caps {
+		rows = append(rows, recordRows(i, c)...)
+	}
+	return
+}
+
+//recordRows flattens a capability into one row per feature
+func recordRows(record int, c TLSClientCapability) (rows []FeatureRow) {
+	positions := map[string]int32{}
+	for _, f := range features(c.Capability) {
+		rows = append(rows, FeatureRow{
+			Record:         int64(record),
+			Browser:        c.ClientDescription.Browser,
+			BrowserVersion: c.ClientDescription.BrowserVersion,
+			OS:             c.ClientDescription.OS,
+			JA3Hash:        c.Fingerprints.JA3Hash,
+			JA4:            c.Fingerprints.JA4,
+			Grade:          c.Assessment.Grade,
+			Score:          int32(c.Assessment.Score),
+			Feature:        f.kind,
+			Code:           f.code,
+			Value:          f.value,
+			Position:       positions[f.kind],
+		})
+		positions[f.kind]++
+	}
+	return
+}
+
+//WideCSVWriter streams capabilities as CSV with one row per record and a true/false column for each version, cipher suite,
+//curve, point format, signature scheme and application protocol offered by any of the records. Feature columns are named
+//kind:name, e.g. cipher:TLS_AES_128_GCM_SHA256, and ordered by kind and then code point. As the columns are only known once
+//every record is written, the rows are held in a temporary file until Close
+type WideCSVWriter struct {
+	out     io.Writer
+	spool   *os.File
+	rows    *csv.Writer //the record values and the columns offered, of each record
+	seen    map[string]bool
+	columns []feature
+	records int
+}
+
+//NewWideCSVWriter starts a wide CSV export
+func NewWideCSVWriter(out io.Writer) (*WideCSVWriter, error) {
+	spool, err := ioutil.TempFile("", "wide-csv-*")
+	if err != nil {
+		return nil, err
+	}
+	return &WideCSVWriter{out: out, spool: spool, rows: csv.NewWriter(spool), seen: map[string]bool{}}, nil
+}
+
+//Write appends a capability to the export
+func (w *WideCSVWriter) Write(c TLSClientCapability) error {
+	row := recordValues(w.records, c)
+	for _, f := range features(c.Capability) {
+		if !w.seen[f.column()] {
+			w.seen[f.column()] = true
+			w.columns = append(w.columns, f)
+		}
+		row = append(row, f.column())
+	}
+	w.records++
+	return w.rows.Write(row)
+}
+
+//Close writes the export and removes the temporary file
+func (w *WideCSVWriter) Close() error {
+	defer os.Remove(w.spool.Name())
+	defer w.spool.Close()
+	w.rows.Flush()
+	if err := w.rows.Error(); err != nil {
+		return err
+	}
+	if _, err := w.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	kinds := map[string]int{}
+	for i, k := range featureKinds {
+		kinds[k] = i
+	}
+	columns := w.columns
+	sort.SliceStable(columns, func(i, j int) bool {
+		a, b := columns[i], columns[j]
+		if a.kind != b.kind {
+			return kinds[a.kind] < kinds[b.kind]
+		}
+		if a.code != b.code {
+			return a.code < b.code
+		}
+		return a.value < b.value
+	})
+
+	out := csv.NewWriter(w.out)
+	header := append([]string{}, recordColumns...)
+	for _, f := range columns {
+		header = append(header, f.column())
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	spooled := csv.NewReader(w.spool)
+	spooled.FieldsPerRecord = -1
+	for {
+		record, err := spooled.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		offered := map[string]bool{}
+		for _, column := range record[len(recordColumns):] {
+			offered[column] = true
+		}
+		row := record[:len(recordColumns)]
+		for _, f := range columns {
+			row = append(row, strconv.FormatBool(offered[f.column()]))
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+//LongCSVWriter streams capabilities as CSV with one row per record and feature, see FeatureRow
+type LongCSVWriter struct {
+	out     *csv.Writer
+	records int
+}
+
+//NewLongCSVWriter starts a long CSV export with its header
+func NewLongCSVWriter(out io.Writer) (*LongCSVWriter, error) {
+	w := csv.NewWriter(out)
+	if err := w.Write(append(append([]string{}, recordColumns...), "feature", "code", "value", "position")); err != nil {
+		return nil, err
+	}
+	return &LongCSVWriter{out: w}, nil
+}
+
+//Write appends the rows of a capability to the export
+func (w *LongCSVWriter) Write(c TLSClientCapability) error {
+	for _, r := range recordRows(w.records, c) {
+		row := []string{strconv.FormatInt(r.Record, 10), r.Browser, r.BrowserVersion, r.OS, r.JA3Hash, r.JA4, r.Grade,
+			strconv.Itoa(int(r.Score)), r.Feature, r.Code, r.Value, strconv.Itoa(int(r.Position))}
+		if err := w.out.Write(row); err != nil {
+			return err
+		}
+	}
+	w.records++
+	return nil
+}
+
+//Close flushes the export
+func (w *LongCSVWriter) Close() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+//LongParquetWriter streams capabilities as a Snappy compressed Parquet file with one row per record and feature, see FeatureRow.
+//Rows are buffered a row group at a time
+type LongParquetWriter struct {
+	out     *writer.ParquetWriter
+	records int
+}
+
+//NewLongParquetWriter starts a Parquet export
+func NewLongParquetWriter(out io.Writer) (*LongParquetWriter, error) {
+	pw, err := writer.NewParquetWriterFromWriter(out, new(FeatureRow), 4)
+	if err != nil {
+		return nil, err
+	}
+	return &LongParquetWriter{out: pw}, nil
+}
+
+//Write appends the rows of a capability to the export
+func (w *LongParquetWriter) Write(c TLSClientCapability) error {
+	for _, r := range recordRows(w.records, c) {
+		if err := w.out.Write(r); err != nil {
+			return err
+		}
+	}
+	w.records++
+	return nil
+}
+
+//Close writes the buffered rows and the footer of the file
+func (w *LongParquetWriter) Close() error {
+	return w.out.WriteStop()
+}
+
+//WriteWideCSV writes the capabilities with a WideCSVWriter
+func WriteWideCSV(out io.Writer, caps []TLSClientCapability) error {
+	w, err := NewWideCSVWriter(out)
+	if err != nil {
+		return err
+	}
+	return exportAll(w, caps)
+}
+
+//WriteLongCSV writes the capabilities with a LongCSVWriter
+func WriteLongCSV(out io.Writer, caps []TLSClientCapability) error {
+	w, err := NewLongCSVWriter(out)
+	if err != nil {
+		return err
+	}
+	return exportAll(w, caps)
+}
+
+//WriteLongParquet writes the capabilities with a LongParquetWriter
+func WriteLongParquet(out io.Writer, caps []TLSClientCapability) error {
+	w, err := NewLongParquetWriter(out)
+	if err != nil {
+		return err
+	}
+	return exportAll(w, caps)
+}
+
+func exportAll(w CapabilityExporter, caps []TLSClientCapability) error {
+	for _, c := range caps {
+		if err := w.Write(c); err != nil {
+			w.Close()
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func recordValues(record int, c TLSClientCapability) []string {
+	return []string{strconv.Itoa(record), c.ClientDescription.Browser, c.ClientDescription.BrowserVersion, c.ClientDescription.OS,
+		c.Fingerprints.JA3Hash, c.Fingerprints.JA4, c.Assessment.Grade, strconv.Itoa(c.Assessment.Score)}
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/csv"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func testExportData() []TLSClientCapability {
+	modern := testCapability([]uint16{0x0a0a, tls.VersionTLS13, tls.VersionTLS12}, []uint16{0x0a0a, 0x1301, 0xc02f}, []tls.CurveID{tls.X25519},
+		[]tls.SignatureScheme{tls.PSSWithSHA256})
+	modern.SupportedProtos = []string{"h2"}
+	modern.SupportedPoints = []uint8{0}
+	legacy := testCapability([]uint16{tls.VersionTLS10}, []uint16{0x002f}, nil, nil)
+	return []TLSClientCapability{
+		{ClientDescription: ClientDescription{Browser: "Chrome", BrowserVersion: "80.0", OS: "Linux"}, Capability: modern, Assessment: Assess(modern)},
+		{ClientDescription: ClientDescription{Browser: "IE", BrowserVersion: "8.0", OS: "Windows XP"}, Capability: legacy, Assessment: Assess(legacy)},
+	}
+}
+
+func TestWriteWideCSV(t *testing.T) {
+	out := bytes.Buffer{}
+	if err := WriteWideCSV(&out, testExportData()); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expects a header and 2 rows, got %d", len(rows))
+	}
+	//GREASE values have no columns, versions lead the features, and codes order each kind
+	expected := append(append([]string{}, recordColumns...), "version:TLS v1.0", "version:TLS v1.2", "version:TLS v1.3",
+		"cipher:TLS_RSA_WITH_AES_128_CBC_SHA", "cipher:TLS_AES_128_GCM_SHA256", "cipher:TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+		"curve:x25519", "point:uncompressed", "scheme:PSSWithSHA256", "alpn:h2")
+	if len(rows[0]) != len(expected) {
+		t.Fatalf("Unexpected header %v", rows[0])
+	}
+	for i, c := range expected {
+		if rows[0][i] != c {
+			t.Errorf("Expects column %d to be %s, got %s", i, c, rows[0][i])
+		}
+	}
+	if rows[1][1] != "Chrome" || rows[1][6] != "A" || rows[1][8] != "false" || rows[1][10] != "true" || rows[1][17] != "true" {
+		t.Errorf("Unexpected Chrome row %v", rows[1])
+	}
+	if rows[2][0] != "1" || rows[2][8] != "true" || rows[2][11] != "true" || rows[2][17] != "false" {
+		t.Errorf("Unexpected IE row %v", rows[2])
+	}
+}
+
+func TestLongExports(t *testing.T) {
+	data := testExportData()
+	rows := LongRows(data)
+	if len(rows) != 10 {
+		t.Fatalf("Expects 10 rows, got %d: %+v", len(rows), rows)
+	}
+	if r := rows[3]; r.Record != 0 || r.Feature != FeatureCipherSuite || r.Code != "0xc02f" || r.Position != 1 {
+		t.Errorf("Unexpected row %+v", r)
+	}
+	if r := rows[9]; r.Record != 1 || r.Browser != "IE" || r.Feature != FeatureCipherSuite || r.Position != 0 {
+		t.Errorf("Unexpected row %+v", r)
+	}
+
+	out := bytes.Buffer{}
+	if err := WriteLongCSV(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(records) != 11 || records[8][10] != "h2" || records[8][9] != "" {
+		t.Errorf("Unexpected long CSV %v %v", records, err)
+	}
+
+	out.Reset()
+	if err := WriteLongParquet(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(file, new(FeatureRow), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	read := make([]FeatureRow, pr.GetNumRows())
+	if err := pr.Read(&read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(rows) {
+		t.Fatalf("Expects %d Parquet rows, got %d", len(rows), len(read))
+	}
+	for i := range rows {
+		if read[i] != rows[i] {
+			t.Errorf("Expects Parquet row %d to be %+v, got %+v", i, rows[i], read[i])
+		}
+	}
+}
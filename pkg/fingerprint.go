@@ -0,0 +1,105 @@
+package model
+
+import (
+	"crypto/md5"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//Fingerprints are the fingerprints computed for a TLS client. The TLS fingerprints are empty for records without a raw ClientHello
+//and JA4H is empty for records without the HTTP request or the order of its headers
+type Fingerprints struct {
+	JA3     string
+	JA3Hash string
+	JA4     string
+	JA4H    string
+}
+
+//Matches reports whether fingerprint is any of the fingerprints. A JA4 or JA4H fingerprint may be given with trailing sections omitted, e.g. "t13d1516h2"
+func (f Fingerprints) Matches(fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+	for _, fp := range []string{f.JA3, f.JA3Hash} {
+		if fp == fingerprint {
+			return true
+		}
+	}
+	for _, fp := range []string{f.JA4, f.JA4H} {
+		if fp == fingerprint || strings.HasPrefix(fp, fingerprint+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+//FilterByFingerprint selects the client capabilities with the given JA3, JA3 hash, JA4 or JA4H fingerprint
+func FilterByFingerprint(data []TLSClientCapability, fingerprint string) (out []TLSClientCapability) {
+	for _, d := range data {
+		if d.Fingerprints.Matches(fingerprint) {
+			out = append(out, d)
+		}
+	}
+	return
+}
+
+//IsGREASE reports whether v is one of the reserved GREASE values of RFC 8701 (0x0a0a, 0x1a1a, ... 0xfafa)
+func IsGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+//JA3 computes the JA3 fingerprint string of a ClientHello and its MD5 hash, see https://github.com/salesforce/ja3
+//The string is SSLVersion,Ciphers,Extensions,EllipticCurves,EllipticCurvePointFormats with GREASE values removed
+func JA3(hello *ClientHello) (ja3, hash string) {
+	points := []uint16{}
+	for _, p := range hello.SupportedPoints {
+		points = append(points, uint16(p))
+	}
+	ja3 = strings.Join([]string{
+		strconv.Itoa(int(hello.Version)),
+		joinDecimal(hello.CipherSuites),
+		joinDecimal(hello.ExtensionTypes()),
+		joinDecimal(hello.SupportedGroups),
+		joinDecimal(points),
+	}, ",")
+	return ja3, md5Hex(ja3)
+}
+
+//JA3S computes the JA3S fingerprint string of a ServerHello and its MD5 hash.
+//The string is SSLVersion,Cipher,Extensions
+func JA3S(hello *ServerHello) (ja3s, hash string) {
+	ja3s = strings.Join([]string{
+		strconv.Itoa(int(hello.Version)),
+		strconv.Itoa(int(hello.CipherSuite)),
+		joinDecimal(hello.ExtensionTypes()),
+	}, ",")
+	return ja3s, md5Hex(ja3s)
+}
+
+//Fingerprints computes the fingerprints of the captured ClientHello and HTTP request
+func (t TLSInfoAndAgent) Fingerprints() (fp Fingerprints) {
+	if t.ClientHello != nil {
+		fp.JA3, fp.JA3Hash = JA3(t.ClientHello)
+		fp.JA4 = JA4(t.ClientHello)
+	}
+	if t.HTTPRequest != nil {
+		fp.JA4H = JA4H(t.HTTPRequest)
+	}
+	return
+}
+
+//joinDecimal joins the non-GREASE values in decimal, separated by "-"
+func joinDecimal(values []uint16) string {
+	out := []string{}
+	for _, v := range values {
+		if !IsGREASE(v) {
+			out = append(out, strconv.Itoa(int(v)))
+		}
+	}
+	return strings.Join(out, "-")
+}
+
+func md5Hex(s string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+}
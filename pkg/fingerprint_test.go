@@ -0,0 +1,113 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestJA3SpecExample(t *testing.T) {
+	//the worked example from https://github.com/salesforce/ja3
+	hello := &ClientHello{
+		Version:         769,
+		CipherSuites:    []uint16{47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+		Extensions:      []Extension{{Type: 0}, {Type: 10}, {Type: 11}},
+		SupportedGroups: []uint16{23, 24, 25},
+		SupportedPoints: []uint8{0},
+	}
+	ja3, hash := JA3(hello)
+	if ja3 != "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0" {
+		t.Errorf("Unexpected JA3 string %s", ja3)
+	}
+	if hash != "ada70206e40642a3e4461f35503241d5" {
+		t.Errorf("Unexpected JA3 hash %s", hash)
+	}
+
+	//GREASE values anywhere in the hello must not change the fingerprint
+	greased := &ClientHello{
+		Version:         769,
+		CipherSuites:    append([]uint16{0x0a0a}, hello.CipherSuites...),
+		Extensions:      []Extension{{Type: 0xdada}, {Type: 0}, {Type: 10}, {Type: 11}, {Type: 0x1a1a}},
+		SupportedGroups: []uint16{0xeaea, 23, 24, 25},
+		SupportedPoints: []uint8{0},
+	}
+	if ja3Greased, _ := JA3(greased); ja3Greased != ja3 {
+		t.Errorf("GREASE not stripped: %s", ja3Greased)
+	}
+}
+
+func TestJA3S(t *testing.T) {
+	hello := &ServerHello{
+		Version:     769,
+		CipherSuite: 47,
+		Extensions:  []Extension{{Type: 65281}, {Type: 0}, {Type: 11}, {Type: 35}, {Type: 5}, {Type: 16}},
+	}
+	ja3s, hash := JA3S(hello)
+	if ja3s != "769,47,65281-0-11-35-5-16" || hash != "836ce314215654b5b1f85f97c73e506f" {
+		t.Errorf("Unexpected JA3S %s %s", ja3s, hash)
+	}
+}
+
+func TestIsGREASE(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		v := uint16(i<<12 | 0x0a<<8 | i<<4 | 0x0a)
+		if !IsGREASE(v) {
+			t.Errorf("%#04x should be GREASE", v)
+		}
+	}
+	for _, v := range []uint16{0x0a1a, 0x1301, 0x001d, 0xc02b, 0x0a0b} {
+		if IsGREASE(v) {
+			t.Errorf("%#04x should not be GREASE", v)
+		}
+	}
+}
+
+func TestFingerprintsOfCapturedHello(t *testing.T) {
+	hello, err := ParseClientHello(captureGoClientHello(t, &tls.Config{ServerName: "example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := TLSInfoAndAgent{ClientHello: hello}.Fingerprints()
+	if !strings.HasPrefix(fp.JA3, "771,") || len(fp.JA3Hash) != 32 {
+		t.Errorf("Unexpected fingerprints %#v", fp)
+	}
+	if fp := (TLSInfoAndAgent{}).Fingerprints(); fp.JA3 != "" {
+		t.Errorf("Expected no fingerprint without a ClientHello, got %#v", fp)
+	}
+}
+
+//capturedHello is a ClientHello captured from a real client, with its JA3 computed independently of this package
+type capturedHello struct {
+	Client      string
+	ClientHello []byte //the TLS records carrying the ClientHello
+	JA3         string
+	JA3Hash     string
+}
+
+//TestJA3OfCapturedClients fingerprints the ClientHellos of curl, openssl s_client and Go's crypto/tls, captured from the wire
+//by a plain TCP listener
+func TestJA3OfCapturedClients(t *testing.T) {
+	data, err := ioutil.ReadFile(path.Join("testdata", "clienthellos.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	captures := []capturedHello{}
+	if err := json.Unmarshal(data, &captures); err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) == 0 {
+		t.Fatal("Expects captured ClientHellos")
+	}
+	for _, c := range captures {
+		hello, err := ParseClientHello(c.ClientHello)
+		if err != nil {
+			t.Fatalf("%s: %s", c.Client, err)
+		}
+		if ja3, hash := JA3(hello); ja3 != c.JA3 || hash != c.JA3Hash {
+			t.Errorf("%s: expected JA3 %s (%s), got %s (%s)", c.Client, c.JA3, c.JA3Hash, ja3, hash)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	tlsdefs "github.com/adedayo/tls-definitions"
+)
+
+//Severity is how much a finding weakens a client's TLS security
+type Severity string
+
+//Severities of findings, from the most to the least severe
+const (
+	SeverityCritical Severity = "Critical"
+	SeverityHigh     Severity = "High"
+	SeverityMedium   Severity = "Medium"
+	SeverityLow      Severity = "Low"
+)
+
+//Codes of the findings reported by Assess
+const (
+	FindingNullCipher        = "NULL_CIPHER"
+	FindingAnonymous         = "ANONYMOUS"
+	FindingExport            = "EXPORT"
+	FindingRC4               = "RC4"
+	FindingDES               = "DES"
+	Finding3DES              = "3DES"
+	FindingCBCOnly           = "CBC_ONLY"
+	FindingNoAEAD            = "NO_AEAD"
+	FindingNoForwardSecrecy  = "NO_FORWARD_SECRECY"
+	FindingMaxVersionBelow12 = "MAX_VERSION_BELOW_TLS12"
+	FindingWeakSignatureMD5  = "WEAK_SIGNATURE_MD5"
+	FindingWeakSignatureSHA1 = "WEAK_SIGNATURE_SHA1"
+)
+
+//penalties are the score deductions of each severity
+var penalties = map[Severity]int{
+	SeverityCritical: 40,
+	SeverityHigh:     20,
+	SeverityMedium:   10,
+	SeverityLow:      5,
+}
+
+//Finding is a weakness found in a client's TLS capability
+type Finding struct {
+	Severity    Severity
+	Code        string
+	Description string
+}
+
+//Assessment is the security grade of a client's TLS capability
+type Assessment struct {
+	Grade    string //A to F
+	Score    int    //0 to 100
+	Findings []Finding
+}
+
+//Assess grades a TLS capability. The score starts at 100 and each finding deducts according to its severity.
+//A critical finding caps the grade at F and a high one at C
+func Assess(cap TLSCapability) Assessment {
+	findings := []Finding{}
+	add := func(severity Severity, code, format string, args ...interface{}) {
+		findings = append(findings, Finding{Severity: severity, Code: code, Description: fmt.Sprintf(format, args...)})
+	}
+
+	var null, anon, export, rc4, des, tripleDES []string
+	aead, fs, nonCBC, suites := false, false, false, 0
+	for _, id := range cap.CipherSuites {
+		cs, ok := ParseCipherSuite(id)
+		if !ok {
+			continue
+		}
+		suites++
+		switch {
+		case cs.Null():
+			null = append(null, cs.Name)
+		case cs.RC4():
+			rc4 = append(rc4, cs.Name)
+		case cs.DES():
+			des = append(des, cs.Name)
+		case cs.TripleDES():
+			tripleDES = append(tripleDES, cs.Name)
+		}
+		if cs.Anonymous() {
+			anon = append(anon, cs.Name)
+		}
+		if cs.Export {
+			export = append(export, cs.Name)
+		}
+		aead = aead || cs.AEAD()
+		fs = fs || cs.ForwardSecret()
+		nonCBC = nonCBC || !cs.CBC()
+	}
+
+	if len(null) > 0 {
+		add(SeverityCritical, FindingNullCipher, "Offers cipher suites without encryption: %s", strings.Join(null, ", "))
+	}
+	if len(anon) > 0 {
+		add(SeverityCritical, FindingAnonymous, "Offers anonymous cipher suites without server authentication: %s", strings.Join(anon, ", "))
+	}
+	if len(export) > 0 {
+		add(SeverityCritical, FindingExport, "Offers export grade cipher suites: %s", strings.Join(export, ", "))
+	}
+	if len(rc4) > 0 {
+		add(SeverityHigh, FindingRC4, "Offers RC4 cipher suites: %s", strings.Join(rc4, ", "))
+	}
+	if len(des) > 0 {
+		add(SeverityHigh, FindingDES, "Offers single DES cipher suites: %s", strings.Join(des, ", "))
+	}
+	if len(tripleDES) > 0 {
+		add(SeverityMedium, Finding3DES, "Offers 3DES cipher suites, vulnerable to Sweet32: %s", strings.Join(tripleDES, ", "))
+	}
+	if suites > 0 {
+		if !nonCBC {
+			add(SeverityMedium, FindingCBCOnly, "Offers only CBC mode cipher suites")
+		}
+		if !aead {
+			add(SeverityMedium, FindingNoAEAD, "Offers no AEAD cipher suites")
+		}
+		if !fs {
+			add(SeverityHigh, FindingNoForwardSecrecy, "Offers no cipher suites with forward secrecy")
+		}
+	}
+
+	if max := maxVersion(cap.SupportedVersions); max != 0 && max < tls.VersionTLS12 {
+		add(SeverityHigh, FindingMaxVersionBelow12, "Supports at most %s", versionName(max))
+	}
+
+	var md5, sha1 []string
+	for _, s := range cap.SignatureSchemes {
+		switch hash, sig := uint16(s)>>8, uint16(s)&0xff; {
+		case hash == 1 && sig <= 3:
+			md5 = append(md5, signatureSchemeName(s))
+		case hash == 2 && sig <= 3:
+			sha1 = append(sha1, signatureSchemeName(s))
+		}
+	}
+	if len(md5) > 0 {
+		add(SeverityMedium, FindingWeakSignatureMD5, "Offers MD5 signature schemes: %s", strings.Join(md5, ", "))
+	}
+	if len(sha1) > 0 {
+		add(SeverityLow, FindingWeakSignatureSHA1, "Offers SHA-1 signature schemes: %s", strings.Join(sha1, ", "))
+	}
+
+	return grade(findings)
+}
+
+func grade(findings []Finding) Assessment {
+	score, critical, high := 100, false, false
+	for _, f := range findings {
+		score -= penalties[f.Severity]
+		critical = critical || f.Severity == SeverityCritical
+		high = high || f.Severity == SeverityHigh
+	}
+	if score < 0 {
+		score = 0
+	}
+	a := Assessment{Score: score, Findings: findings}
+	switch {
+	case critical || score < 50:
+		a.Grade = "F"
+	case score < 65:
+		a.Grade = "D"
+	case high || score < 80:
+		a.Grade = "C"
+	case score < 90:
+		a.Grade = "B"
+	default:
+		a.Grade = "A"
+	}
+	return a
+}
+
+func maxVersion(versions []uint16) (max uint16) {
+	for _, v := range versions {
+		if !IsGREASE(v) && v > max {
+			max = v
+		}
+	}
+	return
+}
+
+func versionName(v uint16) string {
+	if name, ok := tlsdefs.TLSVersionMap[v]; ok {
+		return name
+	}
+	return hex([]uint16{v})[0]
+}
+
+func signatureSchemeName(s tls.SignatureScheme) string {
+	if name, ok := tlsdefs.SignatureSchemes[uint16(s)]; ok {
+		return name
+	}
+	return hexSignature([]tls.SignatureScheme{s})[0]
+}
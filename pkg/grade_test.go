@@ -0,0 +1,73 @@
+package model
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func findingCodes(a Assessment) map[string]bool {
+	codes := map[string]bool{}
+	for _, f := range a.Findings {
+		codes[f.Code] = true
+	}
+	return codes
+}
+
+func TestParseCipherSuite(t *testing.T) {
+	cases := []struct {
+		id                           uint16
+		kx, auth, cipher             string
+		aead, fs, anon, null, export bool
+	}{
+		{0x0000, "NULL", "NULL", "NULL_NULL", false, false, true, true, false},
+		{0x0003, "RSA", "RSA", "RC4_40", false, false, false, false, true},
+		{0x0018, "DH", "anon", "RC4_128", false, false, true, false, false},
+		{0x1301, "any", "any", "AES_128_GCM", true, true, false, false, false},
+		{0xc013, "ECDHE", "RSA", "AES_128_CBC", false, true, false, false, false},
+		{0xc0ae, "ECDHE", "ECDSA", "AES_128_CCM_8", true, true, false, false, false},
+	}
+	for _, c := range cases {
+		cs, ok := ParseCipherSuite(c.id)
+		if !ok || cs.KeyExchange != c.kx || cs.Authentication != c.auth || cs.Cipher != c.cipher || cs.AEAD() != c.aead ||
+			cs.ForwardSecret() != c.fs || cs.Anonymous() != c.anon || cs.Null() != c.null || cs.Export != c.export {
+			t.Errorf("Unexpected parse of 0x%04x: %+v", c.id, cs)
+		}
+	}
+	for _, id := range []uint16{0x00ff, 0x5600, 0x0a0a} {
+		if _, ok := ParseCipherSuite(id); ok {
+			t.Errorf("Expected 0x%04x not to be a cipher suite", id)
+		}
+	}
+}
+
+func TestAssess(t *testing.T) {
+	modern := TLSCapability{}
+	modern.CipherSuites = []uint16{0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xcca9, 0xc013, 0x009c, 0x002f}
+	modern.SupportedVersions = []uint16{0x0a0a, tls.VersionTLS13, tls.VersionTLS12}
+	modern.SignatureSchemes = []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256, tls.PSSWithSHA256, tls.PKCS1WithSHA256}
+	if a := Assess(modern); a.Grade != "A" || a.Score != 100 || len(a.Findings) != 0 {
+		t.Errorf("Expected a modern client to get an A, got %+v", a)
+	}
+
+	legacy := TLSCapability{}
+	legacy.CipherSuites = []uint16{0x0005, 0x000a, 0x002f, 0x0035, 0x0003}
+	legacy.SupportedVersions = []uint16{tls.VersionTLS10}
+	legacy.SignatureSchemes = []tls.SignatureScheme{tls.PKCS1WithSHA1}
+	a := Assess(legacy)
+	codes := findingCodes(a)
+	for _, code := range []string{FindingExport, FindingRC4, Finding3DES, FindingNoAEAD, FindingNoForwardSecrecy, FindingMaxVersionBelow12, FindingWeakSignatureSHA1} {
+		if !codes[code] {
+			t.Errorf("Expected finding %s, got %+v", code, a.Findings)
+		}
+	}
+	if a.Grade != "F" || codes[FindingCBCOnly] {
+		t.Errorf("Unexpected assessment of a legacy client %+v", a)
+	}
+
+	cbc := TLSCapability{}
+	cbc.CipherSuites = []uint16{0xc013, 0xc014}
+	cbc.SupportedVersions = []uint16{tls.VersionTLS12}
+	if a := Assess(cbc); a.Grade != "B" || !findingCodes(a)[FindingCBCOnly] {
+		t.Errorf("Expected a CBC only client to get a B, got %+v", a)
+	}
+}
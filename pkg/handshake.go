@@ -0,0 +1,315 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	recordTypeChangeCipherSpec = 20
+	recordTypeAlert            = 21
+
+	handshakeTypeServerKeyExchange = 12
+	handshakeTypeServerHelloDone   = 14
+
+	//handshakeTimeout bounds a simulated or probed handshake
+	handshakeTimeout = 10 * time.Second
+)
+
+//alertNames are the descriptions of the TLS alerts a server sends in response to a ClientHello
+var alertNames = map[uint8]string{
+	0:   "close_notify",
+	10:  "unexpected_message",
+	20:  "bad_record_mac",
+	40:  "handshake_failure",
+	42:  "bad_certificate",
+	47:  "illegal_parameter",
+	50:  "decode_error",
+	51:  "decrypt_error",
+	70:  "protocol_version",
+	71:  "insufficient_security",
+	80:  "internal_error",
+	86:  "inappropriate_fallback",
+	109: "missing_extension",
+	110: "unsupported_extension",
+	112: "unrecognized_name",
+	120: "no_application_protocol",
+}
+
+//AlertError is a TLS alert sent by a server in response to a ClientHello
+type AlertError struct {
+	Level       uint8
+	Description uint8
+}
+
+func (e *AlertError) Error() string {
+	if name, ok := alertNames[e.Description]; ok {
+		return fmt.Sprintf("Server alert: %s", name)
+	}
+	return fmt.Sprintf("Server alert: %d", e.Description)
+}
+
+//BuildClientHello synthesises a ClientHello TLS record offering the versions, cipher suites, curves, point formats,
+//signature schemes and application protocols of a recorded capability. A TLS 1.3 capability offers an x25519 key share
+//if it supports x25519, otherwise an empty key share prompting a HelloRetryRequest. serverName is sent as SNI unless empty
+func BuildClientHello(cap TLSCapability, serverName string) ([]byte, error) {
+	maxVer := maxVersion(cap.SupportedVersions)
+	if maxVer == 0 {
+		return nil, errors.New("Expects a capability with at least one supported version")
+	}
+	legacyVersion := maxVer
+	if legacyVersion > tls.VersionTLS12 {
+		legacyVersion = tls.VersionTLS12
+	}
+	random := make([]byte, 32)
+	sessionID := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(sessionID); err != nil {
+		return nil, err
+	}
+
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(handshakeTypeClientHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(legacyVersion)
+		b.AddBytes(random)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(sessionID) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, c := range cap.CipherSuites {
+				b.AddUint16(c)
+			}
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(0) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			addExtension := func(extType uint16, body func(b *cryptobyte.Builder)) {
+				b.AddUint16(extType)
+				b.AddUint16LengthPrefixed(body)
+			}
+			if serverName != "" {
+				addExtension(extensionServerName, func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddUint8(0) //host_name
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte(serverName)) })
+					})
+				})
+			}
+			if len(cap.SupportedCurves) > 0 {
+				addExtension(extensionSupportedGroups, func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, c := range cap.SupportedCurves {
+							b.AddUint16(uint16(c))
+						}
+					})
+				})
+			}
+			if len(cap.SupportedPoints) > 0 {
+				addExtension(extensionSupportedPoints, func(b *cryptobyte.Builder) {
+					b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(cap.SupportedPoints) })
+				})
+			}
+			if len(cap.SignatureSchemes) > 0 && maxVer >= tls.VersionTLS12 {
+				addExtension(extensionSignatureAlgorithms, func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, s := range cap.SignatureSchemes {
+							b.AddUint16(uint16(s))
+						}
+					})
+				})
+			}
+			if len(cap.SupportedProtos) > 0 {
+				addExtension(extensionALPN, func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, p := range cap.SupportedProtos {
+							b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte(p)) })
+						}
+					})
+				})
+			}
+			if maxVer >= tls.VersionTLS13 {
+				addExtension(extensionSupportedVersions, func(b *cryptobyte.Builder) {
+					b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, v := range cap.SupportedVersions {
+							b.AddUint16(v)
+						}
+					})
+				})
+				addExtension(extensionPSKKeyExchangeModes, func(b *cryptobyte.Builder) {
+					b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddUint8(1) }) //psk_dhe_ke
+				})
+				addExtension(extensionKeyShare, func(b *cryptobyte.Builder) {
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						if !containsCurve(cap.SupportedCurves, tls.X25519) {
+							return
+						}
+						var priv, pub [32]byte
+						if _, err := rand.Read(priv[:]); err != nil {
+							b.SetError(err)
+							return
+						}
+						curve25519.ScalarBaseMult(&pub, &priv)
+						b.AddUint16(uint16(tls.X25519))
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(pub[:]) })
+					})
+				})
+			}
+		})
+	})
+	return handshakeRecord(tls.VersionTLS10, b)
+}
+
+//handshakeRecord wraps the handshake message built by b in a single TLS record
+func handshakeRecord(recordVersion uint16, b *cryptobyte.Builder) ([]byte, error) {
+	msg, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) > 1<<14 {
+		return nil, errors.New("Expects a ClientHello that fits in a single record")
+	}
+	record := []byte{recordTypeHandshake, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(record[1:], recordVersion)
+	binary.BigEndian.PutUint16(record[3:], uint16(len(msg)))
+	return append(record, msg...), nil
+}
+
+//SimulateConfig performs a handshake in memory between a client and a crypto/tls server using config, so the outcome follows
+//Go's own selection rules, including MinVersion, MaxVersion, CipherSuites and CurvePreferences. The client sends its recorded
+//ClientHello when it has one, otherwise one synthesised from its capability by BuildClientHello with config.ServerName, if set, as SNI.
+//
+//Only the unencrypted part of the handshake is observed. A HelloRetryRequest is reported as a successful negotiation of its group.
+//With TLS 1.3 the signature scheme is not visible and the application protocol is the server's first choice offered by the client
+func SimulateConfig(config *tls.Config, client TLSClientCapability) SimulationResult {
+	hello, err := clientHelloBytes(client, config.ServerName)
+	if err != nil {
+		return SimulationResult{Failure: err.Error()}
+	}
+	clientConn, serverConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		tls.Server(serverConn, config.Clone()).Handshake()
+		serverConn.Close()
+		close(done)
+	}()
+	defer func() {
+		clientConn.Close()
+		<-done
+	}()
+
+	_, r := exchangeHellos(clientConn, hello)
+	if r.OK() && r.Version == tls.VersionTLS13 && len(config.NextProtos) > 0 {
+		for _, proto := range config.NextProtos {
+			if containsString(client.Capability.SupportedProtos, proto) {
+				r.ALPN = proto
+				break
+			}
+		}
+	}
+	return r
+}
+
+func clientHelloBytes(client TLSClientCapability, serverName string) ([]byte, error) {
+	if client.ClientHello != nil && len(client.ClientHello.Raw) > 0 {
+		return client.ClientHello.Raw, nil
+	}
+	return BuildClientHello(client.Capability, serverName)
+}
+
+//exchangeHellos sends a ClientHello on conn and reads the server's response up to ServerHelloDone in TLS 1.2 and earlier,
+//or the ServerHello in TLS 1.3
+func exchangeHellos(conn net.Conn, hello []byte) (serverHello *ServerHello, r SimulationResult) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if _, err := conn.Write(hello); err != nil {
+		r.Failure = fmt.Sprintf("Could not send the ClientHello: %s", err.Error())
+		return
+	}
+	var handshake []byte
+	header := make([]byte, recordHeaderLength)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			r.Failure = fmt.Sprintf("Handshake failed: %s", err.Error())
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(header[3:]))
+		if _, err := io.ReadFull(conn, payload); err != nil {
+			r.Failure = fmt.Sprintf("Handshake failed: %s", err.Error())
+			return
+		}
+		switch header[0] {
+		case recordTypeAlert:
+			if len(payload) < 2 {
+				r.Failure = "Handshake failed: malformed alert"
+				return
+			}
+			r.Failure = (&AlertError{Level: payload[0], Description: payload[1]}).Error()
+			return
+		case recordTypeChangeCipherSpec:
+			continue
+		case recordTypeHandshake:
+			handshake = append(handshake, payload...)
+		default:
+			r.Failure = fmt.Sprintf("Handshake failed: unexpected record type %d", header[0])
+			return
+		}
+
+		for len(handshake) >= 4 {
+			length := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+			if len(handshake) < 4+length {
+				break
+			}
+			msgType, body := handshake[0], handshake[4:4+length]
+			handshake = handshake[4+length:]
+			switch msgType {
+			case handshakeTypeServerHello:
+				serverHello = &ServerHello{}
+				if err := serverHello.unmarshal(body); err != nil {
+					r.Failure = err.Error()
+					return nil, r
+				}
+				r.Version = serverHello.NegotiatedVersion()
+				r.CipherSuite = serverHello.CipherSuite
+				r.Group = tls.CurveID(serverHello.KeyShareGroup)
+				r.ALPN = serverHello.ALPNProtocol
+				r.HelloRetryRequest = serverHello.HelloRetryRequest
+				if r.Version >= tls.VersionTLS13 {
+					return
+				}
+			case handshakeTypeServerKeyExchange:
+				if cs, _ := ParseCipherSuite(r.CipherSuite); cs.KeyExchange == "ECDHE" {
+					parseServerKeyExchange(body, r.Version, &r)
+				}
+			case handshakeTypeServerHelloDone:
+				if serverHello == nil {
+					r.Failure = "Handshake failed: ServerHelloDone without a ServerHello"
+				}
+				return
+			}
+		}
+	}
+}
+
+//parseServerKeyExchange reads the named curve and, from TLS 1.2, the signature scheme of an ECDHE ServerKeyExchange
+func parseServerKeyExchange(body []byte, version uint16, r *SimulationResult) {
+	str := cryptobyte.String(body)
+	var curveType uint8
+	var curve uint16
+	var public []byte
+	if !str.ReadUint8(&curveType) || curveType != 3 || !str.ReadUint16(&curve) || !readUint8LengthPrefixedBytes(&str, &public) {
+		return
+	}
+	r.Group = tls.CurveID(curve)
+	var scheme uint16
+	if version >= tls.VersionTLS12 && str.ReadUint16(&scheme) {
+		r.SignatureScheme = tls.SignatureScheme(scheme)
+	}
+}
@@ -0,0 +1,140 @@
+package model
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+//testCertificate creates a self-signed certificate for example.com with an RSA or ECDSA key
+func testCertificate(t *testing.T, keyType string) tls.Certificate {
+	var key crypto.Signer
+	var err error
+	if keyType == KeyTypeRSA {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestBuildClientHello(t *testing.T) {
+	cap := testCapability([]uint16{tls.VersionTLS13, tls.VersionTLS12}, []uint16{0x1301, 0xc02f}, []tls.CurveID{tls.X25519, tls.CurveP256},
+		[]tls.SignatureScheme{tls.PSSWithSHA256})
+	cap.SupportedPoints = []uint8{0}
+	cap.SupportedProtos = []string{"h2"}
+	raw, err := BuildClientHello(cap, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.ServerName != "example.com" || hello.Version != tls.VersionTLS12 || len(hello.CipherSuites) != 2 ||
+		len(hello.SupportedVersions) != 2 || len(hello.KeyShareGroups) != 1 || hello.KeyShareGroups[0] != uint16(tls.X25519) ||
+		len(hello.ALPNProtocols) != 1 || len(hello.SignatureSchemes) != 1 {
+		t.Errorf("Unexpected ClientHello %+v", hello)
+	}
+}
+
+func TestSimulateConfig(t *testing.T) {
+	config := &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t, KeyTypeECDSA)},
+		NextProtos:   []string{"h2", "http/1.1"},
+		MinVersion:   tls.VersionTLS12,
+	}
+	modernSchemes := []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256, tls.PSSWithSHA256}
+	client := func(cap TLSCapability) TLSClientCapability {
+		return TLSClientCapability{Capability: cap}
+	}
+
+	tls13 := testCapability([]uint16{tls.VersionTLS13, tls.VersionTLS12}, []uint16{0x1301, 0xc02b}, []tls.CurveID{tls.X25519, tls.CurveP256}, modernSchemes)
+	tls13.SupportedProtos = []string{"http/1.1", "h2"}
+	if r := SimulateConfig(config, client(tls13)); !r.OK() || r.Version != tls.VersionTLS13 || r.CipherSuite != 0x1301 ||
+		r.Group != tls.X25519 || r.HelloRetryRequest || r.ALPN != "h2" {
+		t.Errorf("Unexpected TLS 1.3 handshake %+v", r)
+	}
+
+	p256 := testCapability([]uint16{tls.VersionTLS13}, []uint16{0x1302}, []tls.CurveID{tls.CurveP256}, modernSchemes)
+	if r := SimulateConfig(config, client(p256)); !r.OK() || r.Group != tls.CurveP256 || !r.HelloRetryRequest {
+		t.Errorf("Expected a HelloRetryRequest for P-256, got %+v", r)
+	}
+
+	tls12 := testCapability([]uint16{tls.VersionTLS12}, []uint16{0x002f, 0xc02b, 0xc009}, []tls.CurveID{tls.CurveP256}, modernSchemes)
+	tls12.SupportedPoints = []uint8{0}
+	tls12.SupportedProtos = []string{"http/1.1"}
+	if r := SimulateConfig(config, client(tls12)); !r.OK() || r.Version != tls.VersionTLS12 || r.CipherSuite != 0xc02b ||
+		r.Group != tls.CurveP256 || r.SignatureScheme != tls.ECDSAWithP256AndSHA256 || r.ALPN != "http/1.1" {
+		t.Errorf("Unexpected TLS 1.2 handshake %+v", r)
+	}
+
+	tls10 := testCapability([]uint16{tls.VersionTLS10}, []uint16{0xc009}, []tls.CurveID{tls.CurveP256}, nil)
+	if r := SimulateConfig(config, client(tls10)); r.Failure != "Server alert: protocol_version" {
+		t.Errorf("Expected a protocol_version alert, got %+v", r)
+	}
+
+	preferences := config.Clone()
+	preferences.CurvePreferences = []tls.CurveID{tls.CurveP256}
+	if r := SimulateConfig(preferences, client(tls13)); !r.OK() || r.Group != tls.CurveP256 || !r.HelloRetryRequest {
+		t.Errorf("Expected the preferred curve P-256, got %+v", r)
+	}
+
+	raw := captureGoClientHello(t, &tls.Config{ServerName: "example.com", MaxVersion: tls.VersionTLS12})
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := SimulateConfig(config, TLSClientCapability{ClientHello: hello}); !r.OK() || r.Version != tls.VersionTLS12 {
+		t.Errorf("Expected a TLS 1.2 handshake with a Go client, got %+v", r)
+	}
+}
+
+func TestSimulateConfigBrowserData(t *testing.T) {
+	config := &tls.Config{Certificates: []tls.Certificate{testCertificate(t, KeyTypeRSA)}}
+	data, err := GetEnrichedData("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ie11, safari10 := 0, 0
+	for _, d := range data {
+		desc := d.ClientDescription
+		switch {
+		case strings.HasPrefix(desc.Browser, "IE") && desc.BrowserVersion == "11.0":
+			ie11++
+		case desc.Browser == "Safari" && strings.HasPrefix(desc.BrowserVersion, "10."):
+			safari10++
+		default:
+			continue
+		}
+		if r := SimulateConfig(config, d); !r.OK() {
+			t.Errorf("Expected %s to connect, got %s", d.Agent, r.Failure)
+		}
+	}
+	if ie11 == 0 || safari10 == 0 {
+		t.Errorf("Expected IE 11 and Safari 10 clients in browser-data.json, got %d and %d", ie11, safari10)
+	}
+}
@@ -0,0 +1,318 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const emptyJA4Hash = "000000000000"
+
+var ja4Versions = map[uint16]string{
+	0x0304: "13",
+	0x0303: "12",
+	0x0302: "11",
+	0x0301: "10",
+	0x0300: "s3",
+	0x0002: "s2",
+	0xfeff: "d1",
+	0xfefd: "d2",
+	0xfefc: "d3",
+}
+
+//HTTPRequestInfo records the parts of the HTTP request that JA4H fingerprints
+type HTTPRequestInfo struct {
+	Method         string
+	Proto          string
+	HeaderNames    []string //excluding pseudo-headers, in the order they were sent if WireOrder, otherwise sorted
+	WireOrder      bool     //the order of HeaderNames is the order on the wire, without which there is no JA4H
+	Cookies        []string //name=value pairs
+	AcceptLanguage string
+}
+
+//NewHTTPRequestInfo extracts the fingerprintable parts of an HTTP request. net/http does not preserve the order of headers,
+//so headerOrder gives the header names as sent, e.g. from RequestHeads. Without it the names are recorded sorted
+func NewHTTPRequestInfo(req *http.Request, headerOrder []string) *HTTPRequestInfo {
+	info := HTTPRequestInfo{
+		Method:         req.Method,
+		Proto:          req.Proto,
+		AcceptLanguage: req.Header.Get("Accept-Language"),
+	}
+	if headerOrder != nil {
+		info.HeaderNames = append([]string{}, headerOrder...)
+		info.WireOrder = true
+	} else {
+		for name := range req.Header {
+			info.HeaderNames = append(info.HeaderNames, name)
+		}
+		if req.ProtoMajor == 1 && req.Host != "" {
+			//net/http moves the Host header out of req.Header
+			info.HeaderNames = append(info.HeaderNames, "Host")
+		}
+		sort.Strings(info.HeaderNames)
+	}
+	for _, c := range req.Cookies() {
+		info.Cookies = append(info.Cookies, c.Name+"="+c.Value)
+	}
+	return &info
+}
+
+const (
+	//maxRequestHead bounds the request line and headers of an HTTP/1.x request followed by RequestHeads
+	maxRequestHead = 1 << 16
+	//maxPendingHeads bounds the requests RequestHeads holds that were never taken
+	maxPendingHeads = 16
+)
+
+//RequestHeads follows the plaintext of an HTTP/1.x connection, written to it as the server reads it, and records the header
+//names of each request in the order they were sent. Bodies are skipped by their Content-Length. A request with a
+//Transfer-Encoding, or malformed, ends the recording for the connection. It is safe for concurrent use
+type RequestHeads struct {
+	mutex  sync.Mutex
+	buffer []byte //an incomplete request head
+	skip   int64  //the bytes of the current request body still to come
+	failed bool
+	heads  []requestHead
+}
+
+type requestHead struct {
+	method, target string
+	names          []string
+}
+
+//Write follows more of the connection. It never fails
+func (r *RequestHeads) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	n := len(p)
+	if r.failed {
+		return n, nil
+	}
+	if r.skip > 0 {
+		k := r.skip
+		if int64(len(p)) < k {
+			k = int64(len(p))
+		}
+		p, r.skip = p[k:], r.skip-k
+	}
+	r.buffer = append(r.buffer, p...)
+	for !r.failed && r.skip == 0 {
+		//empty lines may precede a request line
+		r.buffer = bytes.TrimLeft(r.buffer, "\r\n")
+		end := bytes.Index(r.buffer, []byte("\r\n\r\n"))
+		if end < 0 {
+			r.failed = len(r.buffer) > maxRequestHead
+			break
+		}
+		r.parseHead(string(r.buffer[:end]))
+		r.buffer = r.buffer[end+4:]
+		if k := int64(len(r.buffer)); k < r.skip {
+			r.buffer, r.skip = nil, r.skip-k
+		} else {
+			r.buffer, r.skip = r.buffer[r.skip:], 0
+		}
+	}
+	if r.failed || len(r.buffer) == 0 {
+		r.buffer = nil
+	}
+	return n, nil
+}
+
+func (r *RequestHeads) parseHead(head string) {
+	lines := strings.Split(head, "\r\n")
+	request := strings.Fields(lines[0])
+	if len(request) != 3 || !strings.HasPrefix(request[2], "HTTP/1.") {
+		r.failed = true
+		return
+	}
+	h := requestHead{method: request[0], target: request[1], names: []string{}}
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue //an obsolete continuation of the previous header
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon <= 0 {
+			r.failed = true
+			return
+		}
+		name, value := line[:colon], strings.TrimSpace(line[colon+1:])
+		h.names = append(h.names, name)
+		switch strings.ToLower(name) {
+		case "content-length":
+			length, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || length < 0 {
+				r.failed = true
+				return
+			}
+			r.skip = length
+		case "transfer-encoding":
+			r.failed = true
+			return
+		}
+	}
+	if len(r.heads) == maxPendingHeads {
+		r.heads = r.heads[1:]
+	}
+	r.heads = append(r.heads, h)
+}
+
+//Take returns the header names, in the order they were sent, of the oldest request recorded with the method and request
+//target, e.g. GET and /browserAudit, and forgets that request and those before it. It returns nil if there is no such request
+func (r *RequestHeads) Take(method, target string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, h := range r.heads {
+		if h.method == method && h.target == target {
+			r.heads = r.heads[i+1:]
+			return h.names
+		}
+	}
+	return nil
+}
+
+//JA4 computes the JA4 fingerprint of a ClientHello received over TCP, see https://github.com/FoxIO-LLC/ja4
+func JA4(hello *ClientHello) string {
+	version := hello.Version
+	if len(hello.SupportedVersions) > 0 {
+		version = 0
+		for _, v := range hello.SupportedVersions {
+			if !IsGREASE(v) && v > version {
+				version = v
+			}
+		}
+	}
+	ver, ok := ja4Versions[version]
+	if !ok {
+		ver = "00"
+	}
+
+	sni := "i"
+	ciphers := []string{}
+	extensions := []string{}
+	extensionCount := 0
+	for _, c := range hello.CipherSuites {
+		if !IsGREASE(c) {
+			ciphers = append(ciphers, fmt.Sprintf("%04x", c))
+		}
+	}
+	for _, e := range hello.ExtensionTypes() {
+		if IsGREASE(e) {
+			continue
+		}
+		extensionCount++
+		switch e {
+		case extensionServerName:
+			sni = "d"
+		case extensionALPN:
+		default:
+			extensions = append(extensions, fmt.Sprintf("%04x", e))
+		}
+	}
+
+	alpn := "00"
+	if len(hello.ALPNProtocols) > 0 && len(hello.ALPNProtocols[0]) > 0 {
+		p := hello.ALPNProtocols[0]
+		first, last := p[0], p[len(p)-1]
+		if isAlphanumeric(first) && isAlphanumeric(last) {
+			alpn = string([]byte{first, last})
+		} else {
+			h := fmt.Sprintf("%x", p)
+			alpn = h[:1] + h[len(h)-1:]
+		}
+	}
+	a := fmt.Sprintf("t%s%s%02d%02d%s", ver, sni, min99(len(ciphers)), min99(extensionCount), alpn)
+
+	sort.Strings(ciphers)
+	sort.Strings(extensions)
+	schemes := []string{}
+	for _, s := range hello.SignatureSchemes {
+		if !IsGREASE(s) {
+			schemes = append(schemes, fmt.Sprintf("%04x", s))
+		}
+	}
+	c := strings.Join(extensions, ",")
+	if len(schemes) > 0 {
+		c += "_" + strings.Join(schemes, ",")
+	}
+	if len(extensions) == 0 {
+		c = ""
+	}
+	return strings.Join([]string{a, ja4Hash(strings.Join(ciphers, ",")), ja4Hash(c)}, "_")
+}
+
+//JA4H computes the JA4H fingerprint of an HTTP request, see https://github.com/FoxIO-LLC/ja4.
+//It is empty unless the order of the headers as sent is known, as the fingerprint hashes them in that order
+func JA4H(req *HTTPRequestInfo) string {
+	if !req.WireOrder {
+		return ""
+	}
+	method := strings.ToLower(req.Method)
+	if len(method) > 2 {
+		method = method[:2]
+	}
+	version := strings.Replace(strings.TrimPrefix(req.Proto, "HTTP/"), ".", "", -1)
+	if len(version) == 1 {
+		version += "0"
+	}
+
+	cookie, referer := "n", "n"
+	headers := []string{}
+	for _, h := range req.HeaderNames {
+		switch strings.ToLower(h) {
+		case "cookie":
+			cookie = "c"
+		case "referer":
+			referer = "r"
+		default:
+			if !strings.HasPrefix(h, ":") {
+				headers = append(headers, h)
+			}
+		}
+	}
+	if len(req.Cookies) > 0 {
+		cookie = "c"
+	}
+
+	lang := strings.Replace(strings.ToLower(req.AcceptLanguage), "-", "", -1)
+	lang = strings.Split(strings.Replace(lang, ";", ",", -1), ",")[0]
+	if len(lang) > 4 {
+		lang = lang[:4]
+	}
+	lang += strings.Repeat("0", 4-len(lang))
+
+	names := []string{}
+	fields := append([]string{}, req.Cookies...)
+	for _, c := range req.Cookies {
+		names = append(names, strings.SplitN(c, "=", 2)[0])
+	}
+	sort.Strings(names)
+	sort.Strings(fields)
+
+	a := fmt.Sprintf("%s%s%s%s%02d%s", method, version, cookie, referer, min99(len(headers)), lang)
+	return strings.Join([]string{a, ja4Hash(strings.Join(headers, ",")),
+		ja4Hash(strings.Join(names, ",")), ja4Hash(strings.Join(fields, ","))}, "_")
+}
+
+//ja4Hash is the first 12 hex characters of the SHA256 hash of s, or all zeros if s is empty
+func ja4Hash(s string) string {
+	if s == "" {
+		return emptyJA4Hash
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))[:12]
+}
+
+func isAlphanumeric(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func min99(n int) int {
+	if n > 99 {
+		return 99
+	}
+	return n
+}
@@ -0,0 +1,122 @@
+package model
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJA4(t *testing.T) {
+	//a Chrome ClientHello, GREASE values included
+	extensions := []Extension{{Type: 0x2a2a}}
+	for _, e := range []uint16{0x0000, 0x0017, 0xff01, 0x000a, 0x000b, 0x0023, 0x0010, 0x0005, 0x000d,
+		0x0012, 0x0033, 0x002d, 0x002b, 0x001b, 0x4469, 0xfe0d, 0x3a3a} {
+		extensions = append(extensions, Extension{Type: e})
+	}
+	hello := &ClientHello{
+		Version: 0x0303,
+		CipherSuites: []uint16{0x8a8a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8,
+			0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+		Extensions:        extensions,
+		ALPNProtocols:     []string{"h2", "http/1.1"},
+		SupportedVersions: []uint16{0xdada, 0x0304, 0x0303},
+		SignatureSchemes:  []uint16{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601},
+	}
+	if ja4 := JA4(hello); ja4 != "t13d1516h2_8daaf6152771_02713d6af862" {
+		t.Errorf("Unexpected JA4 %s", ja4)
+	}
+
+	legacy := &ClientHello{
+		Version:      0x0301,
+		CipherSuites: []uint16{0x0035, 0x002f},
+	}
+	if ja4 := JA4(legacy); ja4 != "t10i020000_"+ja4Hash("002f,0035")+"_000000000000" {
+		t.Errorf("Unexpected JA4 %s", ja4)
+	}
+
+	odd := &ClientHello{Version: 0x0303, ALPNProtocols: []string{"\xabc"}}
+	if ja4 := JA4(odd); !strings.HasPrefix(ja4, "t12i0000a3_") {
+		t.Errorf("Unexpected JA4 for a non-alphanumeric ALPN %s", ja4)
+	}
+}
+
+func TestJA4H(t *testing.T) {
+	req := &HTTPRequestInfo{
+		Method:         "GET",
+		Proto:          "HTTP/1.1",
+		HeaderNames:    []string{"Host", "User-Agent", "Accept", "Accept-Language", "Accept-Encoding", "Referer", "Cookie"},
+		WireOrder:      true,
+		Cookies:        []string{"b=2", "a=1"},
+		AcceptLanguage: "en-US,en;q=0.9",
+	}
+	if ja4h := JA4H(req); ja4h != "ge11cr05enus_f3bb7aa45ec4_1eb7c54d5283_06beefe2b477" {
+		t.Errorf("Unexpected JA4H %s", ja4h)
+	}
+
+	r := httptest.NewRequest("POST", "/browserAudit", nil)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+	r.Header.Set("Accept-Language", "fr")
+	info := NewHTTPRequestInfo(r, []string{"accept-language"})
+	if ja4h := JA4H(info); !strings.HasPrefix(ja4h, "po20nn01fr00_") || !strings.HasSuffix(ja4h, "_000000000000_000000000000") {
+		t.Errorf("Unexpected JA4H %s", ja4h)
+	}
+
+	//without the order of the headers there is no JA4H
+	r.Header.Set("User-Agent", "agent")
+	info = NewHTTPRequestInfo(r, nil)
+	if info.WireOrder || len(info.HeaderNames) != 2 || info.HeaderNames[0] != "Accept-Language" || JA4H(info) != "" {
+		t.Errorf("Expects sorted header names and no JA4H, got %+v", info)
+	}
+}
+
+func TestRequestHeads(t *testing.T) {
+	heads := &RequestHeads{}
+	stream := "GET /browserAudit HTTP/1.1\r\nHost: example.com\r\nUser-Agent: agent\r\nAccept: */*\r\n\r\n" +
+		"POST /identify HTTP/1.1\r\nHost: example.com\r\nContent-Length: 31\r\nContent-Type: application/json\r\n\r\n" +
+		"{\"Agent\": \"GET / HTTP/1.1\\r\\n\"}" +
+		"\r\nGET /browserAudit HTTP/1.1\r\naccept: */*\r\nhost: example.com\r\n\r\n"
+	//as read by the server, in pieces splitting the heads and body
+	for i := 0; i < len(stream); i += 7 {
+		end := i + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		heads.Write([]byte(stream[i:end]))
+	}
+	if names := heads.Take("GET", "/browserAudit"); strings.Join(names, ",") != "Host,User-Agent,Accept" {
+		t.Errorf("Unexpected header names %v", names)
+	}
+	if names := heads.Take("GET", "/browserAudit"); strings.Join(names, ",") != "accept,host" {
+		t.Errorf("Expects the body of the POST skipped and the next request recorded, got %v", names)
+	}
+	if names := heads.Take("POST", "/identify"); names != nil {
+		t.Errorf("Expects the requests before a taken one forgotten, got %v", names)
+	}
+
+	heads.Write([]byte("POST /identify HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n" +
+		"GET /browserAudit HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+	if names := heads.Take("GET", "/browserAudit"); names != nil {
+		t.Errorf("Expects a chunked body to end the recording, got %v", names)
+	}
+}
+
+func TestFilterByFingerprint(t *testing.T) {
+	data := []TLSClientCapability{
+		{Agent: "a", Fingerprints: Fingerprints{JA3Hash: "ada70206e40642a3e4461f35503241d5", JA4: "t13d1516h2_8daaf6152771_02713d6af862"}},
+		{Agent: "b", Fingerprints: Fingerprints{JA4H: "ge11cr05enus_f3bb7aa45ec4_1eb7c54d5283_06beefe2b477"}},
+		{Agent: "c"},
+	}
+	for fp, agent := range map[string]string{
+		"ada70206e40642a3e4461f35503241d5":                    "a",
+		"t13d1516h2":                                          "a",
+		"t13d1516h2_8daaf6152771":                             "a",
+		"ge11cr05enus_f3bb7aa45ec4_1eb7c54d5283_06beefe2b477": "b",
+	} {
+		if out := FilterByFingerprint(data, fp); len(out) != 1 || out[0].Agent != agent {
+			t.Errorf("Expected %s to select %s, got %#v", fp, agent, out)
+		}
+	}
+	if out := FilterByFingerprint(data, ""); len(out) != 0 {
+		t.Errorf("An empty fingerprint should not match, got %#v", out)
+	}
+}
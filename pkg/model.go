@@ -19,6 +19,9 @@ type TLSClientCapability struct {
 	ClientDescription ClientDescription
 	Agent             string
 	Capability        TLSCapability
+	ClientHello       *ClientHello //only available for records captured with the raw ClientHello
+	Fingerprints      Fingerprints
+	Assessment        Assessment //the security grade of the capability
 }
 
 //MarshalJSON serialises TLSCapability to JSON
@@ -31,7 +34,7 @@ func (t TLSCapability) MarshalJSON() ([]byte, error) {
 		"SupportedCurveNames":   t.SupportedCurveNames,
 		"SupportedPoints":       hex8(t.SupportedPoints),
 		"SupportedSchemes":      hexSignature(t.SignatureSchemes),
-		"SupportedSchemeNames":  t.SupportedCurveNames,
+		"SupportedSchemeNames":  t.SignatureSchemeNames,
 		"SupportedVersions":     hex(t.SupportedVersions),
 		"SupportedVersionNames": t.SupportedVersionNames,
 	}
@@ -60,7 +63,7 @@ func (t *TLSCapability) UnmarshalJSON(data []byte) error {
 			if cs, err := parseUint16Strings(k2, v2); err == nil {
 				t.CipherSuites = cs
 			}
-		case "CiperSuiteNames":
+		case "CipherSuiteNames":
 			if names, ok := v2.([]interface{}); ok {
 				for _, n := range names {
 					if name, ok := n.(string); ok {
@@ -88,7 +91,7 @@ func (t *TLSCapability) UnmarshalJSON(data []byte) error {
 			if cs, err := parseSchemesStrings(k2, v2); err == nil {
 				t.SignatureSchemes = cs
 			}
-		case "SignatureSchemeNames":
+		case "SupportedSchemeNames", "SignatureSchemeNames":
 			if names, ok := v2.([]interface{}); ok {
 				for _, n := range names {
 					if name, ok := n.(string); ok {
@@ -115,14 +118,20 @@ func (t *TLSCapability) UnmarshalJSON(data []byte) error {
 
 //RemoteAddressAndAgent is the remote browser's address and user agent information
 type RemoteAddressAndAgent struct {
-	Remote string
-	Agent  string
+	Remote  string
+	Agent   string
+	Request *HTTPRequestInfo
 }
 
 //TLSInfoAndAgent contains the browser's user agent and ClientHelloInfo (TLS capability fingerprint)
 type TLSInfoAndAgent struct {
-	Agent     string
-	HelloInfo *tls.ClientHelloInfo
+	Timestamp   time.Time //when the record was captured, zero for older records
+	Agent       string
+	HelloInfo   *tls.ClientHelloInfo
+	ClientHello *ClientHello     //the full ClientHello, nil for records captured before raw hellos were recorded
+	HTTPRequest *HTTPRequestInfo //the request that reported the user agent, nil for older records
+	ClientHints *ClientHints     //the User-Agent Client Hints of the request, nil if the browser sent none
+	Spoofing    *SpoofingVerdict //whether the TLS fingerprint contradicts the claimed browser, nil if not checked
 }
 
 //TLSCapability essentially mirrors HelloInfo
@@ -141,9 +150,17 @@ type TLSCapability struct {
 
 //ClientDescription represents a TLS client browser, its version and operating system
 type ClientDescription struct {
-	Browser        string
-	BrowserVersion string
-	OS             string
+	Browser         string
+	BrowserVersion  string
+	ChromiumVersion string //the version of Chromium the browser is built on, empty for browsers on other engines
+	Engine          string //the rendering engine: Blink, Gecko, WebKit, Trident, EdgeHTML, Presto or KHTML
+	EngineVersion   string
+	OS              string
+	OSFamily        string //e.g. Windows, macOS, iOS, Android or Linux
+	OSVersion       string //e.g. 10, 10.14 or 12.1. Windows versions before 7 are their NT versions, e.g. 5.1 for XP
+	DeviceClass     string //desktop, phone, tablet, tv or bot
+	Architecture    string //x86, x86_64, WOW64 (32 bit Windows software on 64 bit Windows), arm or arm64. Empty if the agent does not tell
+	Bot             bool   //a crawler or automated client rather than a person's browser
 }
 
 //MarshalJSON serialises TLSInfoAndAgent to JSON
@@ -160,6 +177,24 @@ func (t TLSInfoAndAgent) MarshalJSON() ([]byte, error) {
 			"SupportedVersions": hex(t.HelloInfo.SupportedVersions),
 		},
 	}
+	if !t.Timestamp.IsZero() {
+		m["Timestamp"] = t.Timestamp
+	}
+	if t.HTTPRequest != nil {
+		m["HTTPRequest"] = t.HTTPRequest
+	}
+	if t.ClientHints != nil {
+		m["ClientHints"] = t.ClientHints
+	}
+	if t.Spoofing != nil {
+		m["Spoofing"] = t.Spoofing
+	}
+	if t.ClientHello != nil || t.HTTPRequest != nil {
+		m["Fingerprints"] = t.Fingerprints()
+	}
+	if t.ClientHello != nil {
+		m["ClientHello"] = t.ClientHello
+	}
 	return json.Marshal(m)
 }
 
@@ -176,6 +211,14 @@ func (t *TLSInfoAndAgent) UnmarshalJSON(data []byte) error {
 			if agent, ok := v.(string); ok {
 				t.Agent = agent
 			}
+		case "Timestamp":
+			if ts, ok := v.(string); ok {
+				timestamp, err := time.Parse(time.RFC3339Nano, ts)
+				if err != nil {
+					return err
+				}
+				t.Timestamp = timestamp
+			}
 		case "HelloInfo":
 			hi := tls.ClientHelloInfo{}
 			m2, ok := v.(map[string]interface{})
@@ -220,6 +263,42 @@ func (t *TLSInfoAndAgent) UnmarshalJSON(data []byte) error {
 				}
 			}
 			t.HelloInfo = &hi
+		case "ClientHello":
+			hello, err := clientHelloFromMap(v)
+			if err != nil {
+				return err
+			}
+			t.ClientHello = hello
+		case "HTTPRequest":
+			js, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			req := HTTPRequestInfo{}
+			if err := json.Unmarshal(js, &req); err != nil {
+				return err
+			}
+			t.HTTPRequest = &req
+		case "ClientHints":
+			js, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			hints := ClientHints{}
+			if err := json.Unmarshal(js, &hints); err != nil {
+				return err
+			}
+			t.ClientHints = &hints
+		case "Spoofing":
+			js, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			verdict := SpoofingVerdict{}
+			if err := json.Unmarshal(js, &verdict); err != nil {
+				return err
+			}
+			t.Spoofing = &verdict
 		default:
 			// return fmt.Errorf("Unexpected field %s with value %#v", k, v)
 		}
@@ -323,14 +402,14 @@ func hex8(data []uint8) (out []string) {
 
 func hexCurve(data []tls.CurveID) (out []string) {
 	for _, x := range data {
-		out = append(out, fmt.Sprintf("0x%04x", x))
+		out = append(out, fmt.Sprintf("0x%04x", uint16(x)))
 	}
 	return
 }
 
 func hexSignature(data []tls.SignatureScheme) (out []string) {
 	for _, x := range data {
-		out = append(out, fmt.Sprintf("0x%04x", x))
+		out = append(out, fmt.Sprintf("0x%04x", uint16(x)))
 	}
 	return
 }
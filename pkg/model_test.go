@@ -0,0 +1,69 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+//TestCapabilityCodePoints checks curves and signature schemes are written as their hex code points, not as the hex of their
+//names, which formatting them directly gives as they implement fmt.Stringer
+func TestCapabilityCodePoints(t *testing.T) {
+	capability := TLSCapability{ClientHelloInfo: tls.ClientHelloInfo{
+		SupportedCurves:  []tls.CurveID{tls.X25519, tls.CurveP256},
+		SignatureSchemes: []tls.SignatureScheme{tls.PSSWithSHA256, tls.ECDSAWithP256AndSHA256},
+	}}
+	js, err := json.Marshal(capability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(js, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if curves := fields["SupportedCurves"]; !reflect.DeepEqual(curves, []interface{}{"0x001d", "0x0017"}) {
+		t.Errorf("Unexpected curves %v", curves)
+	}
+	if schemes := fields["SupportedSchemes"]; !reflect.DeepEqual(schemes, []interface{}{"0x0804", "0x0403"}) {
+		t.Errorf("Unexpected signature schemes %v", schemes)
+	}
+
+	decoded := TLSCapability{}
+	if err := json.Unmarshal(js, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.SupportedCurves, capability.SupportedCurves) || !reflect.DeepEqual(decoded.SignatureSchemes, capability.SignatureSchemes) {
+		t.Errorf("Expects the code points read back, got %+v", decoded)
+	}
+}
+
+//TestCapabilityNames checks the names of cipher suites and signature schemes are written under their own keys and read back
+func TestCapabilityNames(t *testing.T) {
+	capability := TLSCapability{
+		CipherSuiteNames:      []string{"TLS_AES_128_GCM_SHA256"},
+		SupportedCurveNames:   []string{"X25519"},
+		SignatureSchemeNames:  []string{"PSSWithSHA256"},
+		SupportedVersionNames: []string{"TLS v1.3"},
+	}
+	js, err := json.Marshal(capability)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(js, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if schemes := fields["SupportedSchemeNames"]; !reflect.DeepEqual(schemes, []interface{}{"PSSWithSHA256"}) {
+		t.Errorf("Expects the signature scheme names, not the curve names, got %v", schemes)
+	}
+
+	decoded := TLSCapability{}
+	if err := json.Unmarshal(js, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.CipherSuiteNames, capability.CipherSuiteNames) ||
+		!reflect.DeepEqual(decoded.SignatureSchemeNames, capability.SignatureSchemeNames) {
+		t.Errorf("Expects the names read back, got %+v", decoded)
+	}
+}
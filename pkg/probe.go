@@ -0,0 +1,102 @@
+package model
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+//ProbeOptions control how a recorded client is replayed against a live server
+type ProbeOptions struct {
+	ServerName string        //the SNI to send in place of the recorded one, empty to keep the recorded ClientHello intact
+	Timeout    time.Duration //bounds the connection and handshake, zero for the default of 10 seconds
+}
+
+//ProbeResult is the response of a live server to a replayed ClientHello
+type ProbeResult struct {
+	SimulationResult
+	ServerHello *ServerHello //nil if the server failed the handshake before sending a ServerHello
+}
+
+//Probe connects to address (host:port) and sends the ClientHello of a recorded client, reading the server's response up to
+//ServerHelloDone in TLS 1.2 and earlier, or the ServerHello in TLS 1.3. Records captured with their ClientHello are replayed byte
+//for byte, save for the SNI if opts.ServerName is set. Other records send a ClientHello synthesised by BuildClientHello.
+//A failed handshake is reported in the result; the error is for failures to connect
+func Probe(ctx context.Context, address string, client TLSClientCapability, opts ProbeOptions) (ProbeResult, error) {
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = handshakeTimeout
+	}
+	var hello []byte
+	var err error
+	if client.ClientHello != nil && len(client.ClientHello.Raw) > 0 && opts.ServerName != "" {
+		hello, err = client.ClientHello.withServerName(opts.ServerName)
+	} else {
+		hello, err = clientHelloBytes(client, opts.ServerName)
+	}
+	if err != nil {
+		return ProbeResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	serverHello, r := exchangeHellos(conn, hello)
+	return ProbeResult{SimulationResult: r, ServerHello: serverHello}, nil
+}
+
+//withServerName re-encodes the ClientHello, in a single record, with serverName in its server_name extension.
+//The order and content of every other field and extension is preserved
+func (c *ClientHello) withServerName(serverName string) ([]byte, error) {
+	sni := cryptobyte.NewBuilder(nil)
+	sni.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(0) //host_name
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes([]byte(serverName)) })
+	})
+	sniData, err := sni.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	extensions := c.Extensions
+	found := false
+	for i, e := range extensions {
+		if e.Type == extensionServerName {
+			extensions = append(append(append([]Extension{}, extensions[:i]...), Extension{Type: extensionServerName, Data: sniData}), extensions[i+1:]...)
+			found = true
+			break
+		}
+	}
+	if !found {
+		extensions = append([]Extension{{Type: extensionServerName, Data: sniData}}, extensions...)
+	}
+
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint8(handshakeTypeClientHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(c.Version)
+		b.AddBytes(c.Random)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(c.SessionID) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, cs := range c.CipherSuites {
+				b.AddUint16(cs)
+			}
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(c.CompressionMethods) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, e := range extensions {
+				b.AddUint16(e.Type)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(e.Data) })
+			}
+		})
+	})
+	return handshakeRecord(c.RecordVersion, b)
+}
@@ -0,0 +1,100 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+)
+
+//startTLSServer serves TLS handshakes on a local port, reporting the SNI of each ClientHello on serverNames
+func startTLSServer(t *testing.T, config *tls.Config) (address string, serverNames chan string) {
+	serverNames = make(chan string, 10)
+	config = config.Clone()
+	config.GetConfigForClient = func(h *tls.ClientHelloInfo) (*tls.Config, error) {
+		serverNames <- h.ServerName
+		return nil, nil
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}(conn)
+		}
+	}()
+	return ln.Addr().String(), serverNames
+}
+
+func TestProbe(t *testing.T) {
+	address, serverNames := startTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{testCertificate(t, KeyTypeECDSA)},
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	raw := captureGoClientHello(t, &tls.Config{ServerName: "example.com"})
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := TLSClientCapability{ClientHello: hello}
+	r, err := Probe(context.Background(), address, client, ProbeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() || r.Version != tls.VersionTLS13 || r.ServerHello == nil || r.ServerHello.KeyShareGroup != uint16(r.Group) {
+		t.Errorf("Unexpected probe of a Go client %+v", r)
+	}
+	if sni := <-serverNames; sni != "example.com" {
+		t.Errorf("Expected the recorded SNI example.com, got %s", sni)
+	}
+
+	r, err = Probe(context.Background(), address, client, ProbeOptions{ServerName: "localhost.test"})
+	if err != nil || !r.OK() {
+		t.Errorf("Unexpected probe with a rewritten SNI %+v %v", r, err)
+	}
+	if sni := <-serverNames; sni != "localhost.test" {
+		t.Errorf("Expected the rewritten SNI localhost.test, got %s", sni)
+	}
+
+	tls10 := testCapability([]uint16{tls.VersionTLS10}, []uint16{0xc009, 0x002f}, []tls.CurveID{tls.CurveP256}, nil)
+	r, err = Probe(context.Background(), address, TLSClientCapability{Capability: tls10}, ProbeOptions{})
+	if err != nil || r.Failure != "Server alert: protocol_version" || r.ServerHello != nil {
+		t.Errorf("Expected a protocol_version alert, got %+v %v", r, err)
+	}
+
+	if _, err := Probe(context.Background(), "127.0.0.1:1", client, ProbeOptions{}); err == nil {
+		t.Error("Expected an error connecting to a closed port")
+	}
+}
+
+func TestClientHelloWithServerName(t *testing.T) {
+	raw := captureGoClientHello(t, &tls.Config{ServerName: "example.com"})
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := hello.withServerName("example.com")
+	if err != nil || !bytes.Equal(same, raw) {
+		t.Errorf("Expected the ClientHello to be unchanged %v", err)
+	}
+	renamed, err := hello.withServerName("other.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello2, err := ParseClientHello(renamed)
+	if err != nil || hello2.ServerName != "other.example.org" || !bytes.Equal(hello2.Random, hello.Random) ||
+		len(hello2.Extensions) != len(hello.Extensions) {
+		t.Errorf("Unexpected renamed ClientHello %+v %v", hello2, err)
+	}
+}
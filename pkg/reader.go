@@ -0,0 +1,174 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+//ReadOptions controls how records are streamed
+type ReadOptions struct {
+	Limit         int  //the maximum number of records to read, 0 for no limit
+	SkipMalformed bool //carry on past malformed records, reporting them all in a *MalformedRecordsError once done
+}
+
+//RecordError is a record that could not be decoded. Line is the line number in a JSON lines file, or the sequence number in a database.
+//Offset is the byte offset of the record in a JSON lines file
+type RecordError struct {
+	Line   int
+	Offset int64
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("Malformed record at line %d (offset %d): %s", e.Line, e.Offset, e.Err.Error())
+}
+
+//Unwrap returns the underlying decoding error
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+//MalformedRecordsError lists the malformed records skipped while streaming with ReadOptions.SkipMalformed
+type MalformedRecordsError struct {
+	Errors []*RecordError
+}
+
+func (e *MalformedRecordsError) Error() string {
+	return fmt.Sprintf("%d malformed records skipped, the first: %s", len(e.Errors), e.Errors[0].Error())
+}
+
+//recordReader applies ReadOptions and context cancellation while streaming records
+type recordReader struct {
+	ctx       context.Context
+	opts      ReadOptions
+	count     int
+	malformed []*RecordError
+}
+
+//next decodes a record and passes it to fn. done is true once the limit is reached
+func (r *recordReader) next(line int, offset int64, data []byte, fn func(TLSInfoAndAgent) error) (done bool, err error) {
+	if err := r.ctx.Err(); err != nil {
+		return true, err
+	}
+	info := TLSInfoAndAgent{}
+	if err := json.Unmarshal(data, &info); err != nil {
+		recErr := &RecordError{Line: line, Offset: offset, Err: err}
+		if !r.opts.SkipMalformed {
+			return true, recErr
+		}
+		r.malformed = append(r.malformed, recErr)
+		return false, nil
+	}
+	if err := fn(info); err != nil {
+		return true, err
+	}
+	r.count++
+	return r.opts.Limit > 0 && r.count >= r.opts.Limit, nil
+}
+
+func (r *recordReader) err() error {
+	if len(r.malformed) > 0 {
+		return &MalformedRecordsError{Errors: r.malformed}
+	}
+	return nil
+}
+
+//ReadRecords streams the JSON lines records read from in to fn. It stops when the input or the context is done,
+//when fn returns an error, or once opts.Limit records have been read
+func ReadRecords(ctx context.Context, in io.Reader, opts ReadOptions, fn func(TLSInfoAndAgent) error) error {
+	r := recordReader{ctx: ctx, opts: opts}
+	reader := bufio.NewReader(in)
+	offset := int64(0)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 {
+			if done, e := r.next(line, offset, trimmed, fn); done {
+				return e
+			}
+		}
+		offset += int64(len(data))
+		if err == io.EOF {
+			return r.err()
+		}
+	}
+}
+
+//IterateRawData streams the browser TLS audit data in dataDir. A *DataNotFoundError is returned if there is no data
+func IterateRawData(ctx context.Context, dataDir string, opts ReadOptions, fn func(TLSInfoAndAgent) error) error {
+	dataPath := path.Join(dataDir, "browser-data.json")
+	in, err := os.Open(dataPath)
+	if os.IsNotExist(err) {
+		return &DataNotFoundError{Path: dataPath, Err: err}
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+	return ReadRecords(ctx, in, opts, fn)
+}
+
+//IterateEnrichedData streams the browser TLS audit data in dataDir with further enrichment and annotations
+func IterateEnrichedData(ctx context.Context, dataDir string, opts ReadOptions, fn func(TLSClientCapability) error) error {
+	return IterateRawData(ctx, dataDir, opts, func(info TLSInfoAndAgent) error {
+		return fn(Enrich(info))
+	})
+}
+
+//LoadCapabilities reads a TLSCapabilities document, such as the enriched-browser-data.json written by cmd/analysis
+func LoadCapabilities(file string) (caps TLSCapabilities, err error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return caps, err
+	}
+	defer in.Close()
+	err = json.NewDecoder(bufio.NewReader(in)).Decode(&caps)
+	return
+}
+
+//CapabilitiesWriter streams a TLSCapabilities document, one capability at a time
+type CapabilitiesWriter struct {
+	out   io.Writer
+	count int
+}
+
+//NewCapabilitiesWriter starts a TLSCapabilities document with the given timestamp
+func NewCapabilitiesWriter(out io.Writer, timestamp time.Time) (*CapabilitiesWriter, error) {
+	ts, err := json.Marshal(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(out, "{\n \"Timestamp\": %s,\n \"Capabilities\": [", ts); err != nil {
+		return nil, err
+	}
+	return &CapabilitiesWriter{out: out}, nil
+}
+
+//Write appends a capability to the document
+func (w *CapabilitiesWriter) Write(capability TLSClientCapability) error {
+	js, err := json.MarshalIndent(capability, "  ", " ")
+	if err != nil {
+		return err
+	}
+	separator := ",\n  "
+	if w.count == 0 {
+		separator = "\n  "
+	}
+	w.count++
+	_, err = fmt.Fprintf(w.out, "%s%s", separator, js)
+	return err
+}
+
+//Close ends the document. It does not close the underlying writer
+func (w *CapabilitiesWriter) Close() error {
+	_, err := io.WriteString(w.out, "\n ]\n}\n")
+	return err
+}
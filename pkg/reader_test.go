@@ -0,0 +1,124 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRecords = `{"Agent":"one","HelloInfo":{"CipherSuites":["0x1301"]}}
+{"Agent":"two","HelloInfo":{"CipherSuites":["0x1302"]}}
+{"Agent":"three",
+{"Agent":"four","HelloInfo":{"CipherSuites":["0x1303"]}}
+
+not json
+{"Agent":"five","HelloInfo":{"CipherSuites":["0x1304"]}}`
+
+func readAgents(ctx context.Context, opts ReadOptions) (agents []string, err error) {
+	err = ReadRecords(ctx, strings.NewReader(testRecords), opts, func(info TLSInfoAndAgent) error {
+		agents = append(agents, info.Agent)
+		return nil
+	})
+	return
+}
+
+func TestReadRecordsMalformed(t *testing.T) {
+	agents, err := readAgents(context.Background(), ReadOptions{})
+	recErr := &RecordError{}
+	if !errors.As(err, &recErr) || recErr.Line != 3 {
+		t.Errorf("Expected a RecordError at line 3, got %v", err)
+	}
+	if strings.Join(agents, ",") != "one,two" {
+		t.Errorf("Unexpected records %v", agents)
+	}
+
+	agents, err = readAgents(context.Background(), ReadOptions{SkipMalformed: true})
+	malformed, ok := err.(*MalformedRecordsError)
+	if !ok || len(malformed.Errors) != 2 || malformed.Errors[0].Line != 3 || malformed.Errors[1].Line != 6 {
+		t.Errorf("Expected malformed lines 3 and 6, got %v", err)
+	} else if offset := int64(strings.Index(testRecords, "not json")); malformed.Errors[1].Offset != offset {
+		t.Errorf("Expected the malformed record at offset %d, got %d", offset, malformed.Errors[1].Offset)
+	}
+	if strings.Join(agents, ",") != "one,two,four,five" {
+		t.Errorf("Unexpected records %v", agents)
+	}
+}
+
+func TestReadRecordsLimit(t *testing.T) {
+	agents, err := readAgents(context.Background(), ReadOptions{Limit: 3, SkipMalformed: true})
+	if err != nil || strings.Join(agents, ",") != "one,two,four" {
+		t.Errorf("Unexpected records %v %v", agents, err)
+	}
+}
+
+func TestReadRecordsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	count := 0
+	err := ReadRecords(ctx, strings.NewReader(testRecords), ReadOptions{SkipMalformed: true}, func(TLSInfoAndAgent) error {
+		count++
+		cancel()
+		return nil
+	})
+	if err != context.Canceled || count != 1 {
+		t.Errorf("Expected to stop after the first record, got %d records and %v", count, err)
+	}
+}
+
+func TestReadRecordsFromBrowserData(t *testing.T) {
+	data, err := ioutil.ReadFile("../browser-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Count(bytes.TrimSpace(data), []byte("\n")) + 1
+	count := 0
+	err = IterateRawData(context.Background(), "..", ReadOptions{}, func(TLSInfoAndAgent) error {
+		count++
+		return nil
+	})
+	if err != nil || count != lines {
+		t.Errorf("Expected all %d records, got %d %v", lines, count, err)
+	}
+}
+
+func TestGetRawDataNotFound(t *testing.T) {
+	data, err := GetRawData(t.TempDir())
+	notFound := &DataNotFoundError{}
+	if !errors.As(err, &notFound) || !os.IsNotExist(errors.Unwrap(err)) || data != nil {
+		t.Errorf("Expected a DataNotFoundError, got %v", err)
+	}
+	if _, err := GetEnrichedData(t.TempDir()); !errors.As(err, &notFound) {
+		t.Errorf("Expected a DataNotFoundError, got %v", err)
+	}
+}
+
+func TestCapabilitiesWriter(t *testing.T) {
+	buf := bytes.Buffer{}
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	for n := 0; n < 3; n++ {
+		buf.Reset()
+		w, err := NewCapabilitiesWriter(&buf, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			if err := w.Write(TLSClientCapability{Agent: "agent"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w.Close()
+
+		caps := TLSCapabilities{}
+		if err := json.Unmarshal(buf.Bytes(), &caps); err != nil {
+			t.Fatalf("Invalid JSON for %d capabilities: %s\n%s", n, err, buf.String())
+		}
+		if !caps.Timestamp.Equal(now) || len(caps.Capabilities) != n {
+			t.Errorf("Unexpected document %#v", caps)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package model
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	tlsdefs "github.com/adedayo/tls-definitions"
+)
+
+//recommendedVersions are the protocol versions a recommended policy may enable, from the most secure
+var recommendedVersions = []uint16{tls.VersionTLS13, tls.VersionTLS12, tls.VersionTLS11, tls.VersionTLS10}
+
+//recommendedCurves are the key exchange groups a recommended policy may enable, in order of preference
+var recommendedCurves = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+//RecommendOptions select the client population a recommended policy must serve
+type RecommendOptions struct {
+	Coverage           float64                          //the fraction of selected records that must connect, 0 for all of them
+	Select             func(c TLSClientCapability) bool //selects the clients to serve, nil for all
+	CertificateKeyType string                           //the server's certificate key type, RSA if empty
+}
+
+//ClientCount is the number of records of a browser, its version and operating system
+type ClientCount struct {
+	ClientDescription ClientDescription
+	Records           int
+}
+
+//Concession is a setting weaker than the ideal that a recommended policy enables, and the clients that force it
+type Concession struct {
+	Setting string        //the version, cipher suite or curve enabled
+	Reason  string        //why the setting is weaker than the ideal
+	Clients []ClientCount //the clients that connect with the policy but would fail to without the setting, possibly none
+}
+
+//Recommendation is the most restrictive server policy found for a client population
+type Recommendation struct {
+	Policy      ServerPolicy
+	Records     int           //the records selected
+	Covered     int           //the records that can connect with the policy
+	Concessions []Concession  //settings weaker than TLS 1.3 with AEAD and forward secret cipher suites, with the clients that force them
+	Excluded    []ClientCount //clients left out to meet the coverage that cannot connect, the weakest first
+	Unsupported []ClientCount //clients that cannot connect with any setting a policy may recommend
+}
+
+//clientGroup is the records sharing a TLS capability
+type clientGroup struct {
+	capability TLSCapability
+	clients    map[ClientDescription]int
+	records    int
+	best       SimulationResult //the most secure connection the group can make
+}
+
+//Recommend searches for the most restrictive server policy, enabling the fewest and strongest versions, cipher suites and curves,
+//that lets the selected clients connect. With a coverage below 1, the clients whose best possible connection is weakest are left out.
+//Cipher suites without encryption or authentication, export and single DES suites are never recommended
+func Recommend(clients []TLSClientCapability, opts RecommendOptions) Recommendation {
+	keyType := opts.CertificateKeyType
+	if keyType == "" {
+		keyType = KeyTypeRSA
+	}
+	coverage := opts.Coverage
+	if coverage <= 0 || coverage > 1 {
+		coverage = 1
+	}
+	rec := Recommendation{}
+	full := ServerPolicy{
+		Versions:                 recommendedVersions,
+		CipherSuites:             recommendedCipherSuites(),
+		Curves:                   recommendedCurves,
+		CertificateKeyType:       keyType,
+		PreferServerCipherSuites: true,
+	}
+
+	groups := []*clientGroup{}
+	index := map[string]*clientGroup{}
+	for _, c := range clients {
+		if opts.Select != nil && !opts.Select(c) {
+			continue
+		}
+		rec.Records++
+		key := capabilityKey(c.Capability)
+		g, ok := index[key]
+		if !ok {
+			g = &clientGroup{capability: c.Capability, clients: map[ClientDescription]int{}}
+			g.best = Simulate(full, c.Capability)
+			index[key] = g
+			groups = append(groups, g)
+		}
+		g.records++
+		g.clients[c.ClientDescription.aggregate()]++
+	}
+
+	//order the groups from the strongest best connection, leaving out those that cannot connect at all
+	reachable := []*clientGroup{}
+	unsupported := []*clientGroup{}
+	for _, g := range groups {
+		if g.best.OK() {
+			reachable = append(reachable, g)
+		} else {
+			unsupported = append(unsupported, g)
+		}
+	}
+	sort.SliceStable(reachable, func(i, j int) bool {
+		a, b := reachable[i].best, reachable[j].best
+		if a.Version != b.Version {
+			return a.Version > b.Version
+		}
+		return cipherRank(a.CipherSuite) < cipherRank(b.CipherSuite)
+	})
+	required := int(math.Ceil(coverage * float64(rec.Records)))
+	kept, left := []*clientGroup{}, []*clientGroup{}
+	for _, g := range reachable {
+		if rec.Covered >= required {
+			left = append(left, g)
+			continue
+		}
+		kept = append(kept, g)
+		rec.Covered += g.records
+	}
+	for _, g := range unsupported {
+		rec.Unsupported = append(rec.Unsupported, clientCounts(g)...)
+	}
+
+	policy := ServerPolicy{CertificateKeyType: keyType, PreferServerCipherSuites: true}
+	minVersion := uint16(tls.VersionTLS13)
+	for _, g := range kept {
+		if g.best.Version < minVersion {
+			minVersion = g.best.Version
+		}
+	}
+	for _, v := range recommendedVersions {
+		if v >= minVersion {
+			policy.Versions = append(policy.Versions, v)
+		}
+	}
+
+	//enable the strongest curves until every group needing one has one
+	for _, c := range recommendedCurves {
+		for _, g := range kept {
+			if g.best.Group != 0 && containsCurve(g.capability.SupportedCurves, c) && !sharesCurve(g.capability, policy.Curves) {
+				policy.Curves = append(policy.Curves, c)
+				break
+			}
+		}
+	}
+
+	//enable the strongest cipher suites until every group connects. With the server's preference, every suite enabled
+	//is then negotiated by some client that supports none of the stronger ones
+	connects := func(p ServerPolicy) (uncovered int) {
+		for _, g := range kept {
+			if !Simulate(p, g.capability).OK() {
+				uncovered++
+			}
+		}
+		return
+	}
+	uncovered := connects(policy)
+	for _, cs := range full.CipherSuites {
+		if uncovered == 0 {
+			break
+		}
+		trial := policy
+		trial.CipherSuites = append(append([]uint16{}, policy.CipherSuites...), cs)
+		if n := connects(trial); n < uncovered {
+			policy, uncovered = trial, n
+		}
+	}
+	for _, g := range left {
+		if Simulate(policy, g.capability).OK() {
+			rec.Covered += g.records
+		} else {
+			rec.Excluded = append(rec.Excluded, clientCounts(g)...)
+		}
+	}
+	rec.Policy = policy
+	rec.Concessions = concessions(policy, kept)
+	return rec
+}
+
+//concessions explains the settings of a policy weaker than the ideal by the clients that would fail to connect without them.
+//A client negotiating a setting may not depend on it, as it may connect with another setting of the policy
+func concessions(policy ServerPolicy, groups []*clientGroup) (out []Concession) {
+	connected := []*clientGroup{}
+	for _, g := range groups {
+		if Simulate(policy, g.capability).OK() {
+			connected = append(connected, g)
+		}
+	}
+	concede := func(setting, reason string, without ServerPolicy) {
+		dependants := map[ClientDescription]int{}
+		for _, g := range connected {
+			if !Simulate(without, g.capability).OK() {
+				for d, n := range g.clients {
+					dependants[d] += n
+				}
+			}
+		}
+		out = append(out, Concession{Setting: setting, Reason: reason, Clients: sortedCounts(dependants)})
+	}
+	for _, v := range policy.Versions {
+		if v < tls.VersionTLS13 {
+			without := policy
+			without.Versions = withoutUint16(policy.Versions, v)
+			concede(versionName(v), "Older than TLS v1.3", without)
+		}
+	}
+	for _, id := range policy.CipherSuites {
+		cs, _ := ParseCipherSuite(id)
+		reasons := []string{}
+		if !cs.AEAD() {
+			reasons = append(reasons, "not AEAD")
+		}
+		if !cs.ForwardSecret() {
+			reasons = append(reasons, "no forward secrecy")
+		}
+		if cs.TripleDES() || cs.RC4() {
+			reasons = append(reasons, "weak cipher")
+		}
+		if len(reasons) > 0 {
+			without := policy
+			without.CipherSuites = withoutUint16(policy.CipherSuites, id)
+			concede(cs.Name, strings.Join(reasons, ", "), without)
+		}
+	}
+	for i, c := range policy.Curves {
+		if i > 0 {
+			without := policy
+			without.Curves = append(append([]tls.CurveID{}, policy.Curves[:i]...), policy.Curves[i+1:]...)
+			concede(curveName(c), fmt.Sprintf("Less preferred than %s", curveName(policy.Curves[0])), without)
+		}
+	}
+	return
+}
+
+func withoutUint16(values []uint16, v uint16) (out []uint16) {
+	for _, x := range values {
+		if x != v {
+			out = append(out, x)
+		}
+	}
+	return
+}
+
+//recommendedCipherSuites are the cipher suites a recommended policy may enable, from the most secure
+func recommendedCipherSuites() (out []uint16) {
+	for id := range tlsdefs.CipherSuiteMap {
+		if cs, ok := ParseCipherSuite(id); ok && cipherRank(id) >= 0 {
+			out = append(out, cs.ID)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ri, rj := cipherRank(out[i]), cipherRank(out[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return out[i] < out[j]
+	})
+	return
+}
+
+//cipherRank orders cipher suites from the most secure, 0 for TLS 1.3 suites. It is negative for suites never to be recommended
+func cipherRank(id uint16) int {
+	cs, ok := ParseCipherSuite(id)
+	if !ok || cs.Null() || cs.Anonymous() || cs.Export || cs.DES() {
+		return -1
+	}
+	switch cs.KeyExchange {
+	case "any", "ECDHE", "DHE", "RSA":
+	default:
+		return -1 //PSK, SRP, Kerberos and static DH suites need more than a certificate
+	}
+	if strings.HasPrefix(cs.Cipher, "AES_128_CCM_8") || strings.Contains(cs.Cipher, "CCM") && !cs.TLS13 {
+		return -1 //rarely supported
+	}
+	rank := 0
+	if !cs.TLS13 {
+		rank++
+	}
+	if !cs.AEAD() {
+		rank += 8
+	}
+	if !cs.ForwardSecret() {
+		rank += 4
+	} else if cs.KeyExchange == "DHE" {
+		rank += 2
+	}
+	if !strings.HasPrefix(cs.Cipher, "AES") && !strings.HasPrefix(cs.Cipher, "CHACHA20") {
+		rank += 16 //Camellia, ARIA, SEED and IDEA
+	}
+	if cs.TripleDES() {
+		rank += 32
+	}
+	if cs.RC4() {
+		rank += 64
+	}
+	return rank
+}
+
+func sharesCurve(cap TLSCapability, curves []tls.CurveID) bool {
+	for _, c := range curves {
+		if containsCurve(cap.SupportedCurves, c) {
+			return true
+		}
+	}
+	return false
+}
+
+//capabilityKey identifies the TLS capabilities that negotiate alike
+func capabilityKey(h TLSCapability) string {
+	return fmt.Sprintf("%v,%v,%v,%v,%v,%v", h.SupportedVersions, h.CipherSuites, h.SupportedCurves, h.SupportedPoints, h.SignatureSchemes, h.SupportedProtos)
+}
+
+func clientCounts(g *clientGroup) []ClientCount {
+	return sortedCounts(g.clients)
+}
+
+func sortedCounts(clients map[ClientDescription]int) (out []ClientCount) {
+	for d, n := range clients {
+		out = append(out, ClientCount{ClientDescription: d, Records: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ClientDescription.less(out[j].ClientDescription) })
+	return
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestRecommend(t *testing.T) {
+	modern := TLSClientCapability{
+		ClientDescription: ClientDescription{Browser: "Chrome", BrowserVersion: "80.0", OS: "Linux"},
+		Capability: testCapability([]uint16{tls.VersionTLS13, tls.VersionTLS12}, []uint16{0x1301, 0x1302, 0xc02f, 0xc013, 0x002f},
+			[]tls.CurveID{tls.X25519, tls.CurveP256}, []tls.SignatureScheme{tls.PSSWithSHA256}),
+	}
+	tls12 := TLSClientCapability{
+		ClientDescription: ClientDescription{Browser: "Firefox", BrowserVersion: "50.0", OS: "Linux"},
+		Capability: testCapability([]uint16{tls.VersionTLS12}, []uint16{0xc02f, 0xc013, 0x002f},
+			[]tls.CurveID{tls.CurveP256}, []tls.SignatureScheme{tls.PSSWithSHA256, tls.PKCS1WithSHA256}),
+	}
+	legacy := TLSClientCapability{
+		ClientDescription: ClientDescription{Browser: "IE", BrowserVersion: "8.0", OS: "Windows XP"},
+		Capability:        testCapability([]uint16{tls.VersionTLS10}, []uint16{0x0005, 0x000a, 0x0004}, nil, nil),
+	}
+	insecure := TLSClientCapability{
+		ClientDescription: ClientDescription{Browser: "Insecure", BrowserVersion: "1.0", OS: "Linux"},
+		Capability:        testCapability([]uint16{tls.VersionTLS10}, []uint16{0x0003, 0x0018}, nil, nil),
+	}
+	clients := []TLSClientCapability{modern, modern, modern, tls12, legacy, insecure}
+
+	rec := Recommend(clients, RecommendOptions{})
+	if !reflect.DeepEqual(rec.Policy.Versions, []uint16{tls.VersionTLS13, tls.VersionTLS12, tls.VersionTLS11, tls.VersionTLS10}) ||
+		!reflect.DeepEqual(rec.Policy.CipherSuites, []uint16{0x1301, 0xc02f, 0x000a}) ||
+		!reflect.DeepEqual(rec.Policy.Curves, []tls.CurveID{tls.X25519, tls.CurveP256}) {
+		t.Errorf("Unexpected policy %+v", rec.Policy)
+	}
+	if rec.Records != 6 || rec.Covered != 5 || len(rec.Unsupported) != 1 || rec.Unsupported[0].ClientDescription != insecure.ClientDescription {
+		t.Errorf("Unexpected coverage %+v", rec)
+	}
+	concessions := map[string][]ClientCount{}
+	for _, c := range rec.Concessions {
+		concessions[c.Setting] = c.Clients
+	}
+	if c := concessions["TLS v1.0"]; len(c) != 1 || c[0].ClientDescription != legacy.ClientDescription {
+		t.Errorf("Expected IE 8 to force TLS v1.0, got %+v", c)
+	}
+	if c := concessions["TLS_RSA_WITH_3DES_EDE_CBC_SHA"]; len(c) != 1 || c[0].ClientDescription != legacy.ClientDescription {
+		t.Errorf("Expected IE 8 to force 3DES, got %+v", c)
+	}
+	if c := concessions["secp256r1"]; len(c) != 1 || c[0].ClientDescription != tls12.ClientDescription {
+		t.Errorf("Expected Firefox to force P-256, got %+v", c)
+	}
+	if c, ok := concessions["TLS v1.1"]; !ok || len(c) != 0 {
+		t.Errorf("Expected TLS v1.1 enabled though no client depends on it, got %+v", c)
+	}
+	if _, ok := concessions["TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"]; ok {
+		t.Error("Expected no concession for an AEAD suite with forward secrecy")
+	}
+
+	rec = Recommend(clients, RecommendOptions{Coverage: 0.6})
+	if !reflect.DeepEqual(rec.Policy.Versions, []uint16{tls.VersionTLS13, tls.VersionTLS12}) ||
+		!reflect.DeepEqual(rec.Policy.CipherSuites, []uint16{0x1301, 0xc02f}) ||
+		len(rec.Excluded) != 1 || rec.Excluded[0].ClientDescription != legacy.ClientDescription {
+		t.Errorf("Unexpected policy for 60%% of records %+v", rec)
+	}
+
+	rec = Recommend(clients, RecommendOptions{Select: func(c TLSClientCapability) bool { return c.ClientDescription.Browser == "Chrome" }})
+	if rec.Records != 3 || !reflect.DeepEqual(rec.Policy.Versions, []uint16{tls.VersionTLS13}) || len(rec.Concessions) != 0 {
+		t.Errorf("Unexpected policy for Chrome %+v", rec)
+	}
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"crypto/tls"
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	reportFuncs = template.FuncMap{
+		"check": func(b bool) string {
+			if b {
+				return "✓"
+			}
+			return "✗"
+		},
+		"join": strings.Join,
+		"md":   func(s string) string { return strings.ReplaceAll(s, "|", "\\|") },
+	}
+	htmlReportTemplate     = htmltemplate.Must(htmltemplate.New("report.html").Funcs(htmltemplate.FuncMap(reportFuncs)).ParseFS(templates, "templates/report.html"))
+	markdownReportTemplate = template.Must(template.New("report.md").Funcs(reportFuncs).ParseFS(templates, "templates/report.md"))
+)
+
+//Report is a compatibility matrix of the recorded browsers, their versions and operating systems
+type Report struct {
+	Timestamp time.Time
+	Records   int
+	Rows      []ReportRow
+}
+
+//ReportRow summarises the TLS capability of a browser, its version and operating system. When its records differ,
+//the row describes the most common capability
+type ReportRow struct {
+	ClientDescription ClientDescription
+	Records           int
+	Variants          int    //the number of distinct capabilities recorded
+	MaxVersion        string //the highest protocol version supported
+	AEAD              bool   //offers AEAD cipher suites
+	ForwardSecrecy    bool   //offers forward secret cipher suites
+	TLS13Ciphers      []string
+	H2                bool //offers HTTP/2 by ALPN
+	Grade             string
+	Score             int
+}
+
+//BuildReport summarises client capabilities by browser, version and operating system, sorted in that order
+func BuildReport(caps []TLSClientCapability) Report {
+	type row struct {
+		records  int
+		variants map[string]int
+		capable  map[string]TLSClientCapability
+	}
+	rows := map[ClientDescription]*row{}
+	for _, c := range caps {
+		desc := c.ClientDescription.aggregate()
+		r, ok := rows[desc]
+		if !ok {
+			r = &row{variants: map[string]int{}, capable: map[string]TLSClientCapability{}}
+			rows[desc] = r
+		}
+		key := capabilityKey(c.Capability)
+		r.records++
+		r.variants[key]++
+		r.capable[key] = c
+	}
+
+	report := Report{Timestamp: time.Now(), Records: len(caps)}
+	for desc, r := range rows {
+		common, n := "", 0
+		for key, count := range r.variants {
+			if count > n || count == n && key < common {
+				common, n = key, count
+			}
+		}
+		c := r.capable[common]
+		rr := ReportRow{ClientDescription: desc, Records: r.records, Variants: len(r.variants)}
+		if max := maxVersion(c.Capability.SupportedVersions); max != 0 {
+			rr.MaxVersion = versionName(max)
+		}
+		for _, id := range c.Capability.CipherSuites {
+			if cs, ok := ParseCipherSuite(id); ok {
+				rr.AEAD = rr.AEAD || cs.AEAD()
+				rr.ForwardSecrecy = rr.ForwardSecrecy || cs.ForwardSecret()
+				if cs.TLS13 {
+					rr.TLS13Ciphers = append(rr.TLS13Ciphers, cs.Name)
+				}
+			}
+		}
+		rr.H2 = containsString(c.Capability.SupportedProtos, "h2")
+		assessment := c.Assessment
+		if assessment.Grade == "" {
+			assessment = Assess(c.Capability)
+		}
+		rr.Grade, rr.Score = assessment.Grade, assessment.Score
+		report.Rows = append(report.Rows, rr)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		return report.Rows[i].ClientDescription.less(report.Rows[j].ClientDescription)
+	})
+	return report
+}
+
+//SupportsTLS13 reports whether the row's browser supports TLS 1.3
+func (r ReportRow) SupportsTLS13() bool {
+	return r.MaxVersion == versionName(tls.VersionTLS13)
+}
+
+//WriteHTML renders the report as a self-contained HTML page, with filters by browser, operating system and grade
+func (r Report) WriteHTML(out io.Writer) error {
+	return htmlReportTemplate.Execute(out, r)
+}
+
+//WriteMarkdown renders the report as a Markdown table
+func (r Report) WriteMarkdown(out io.Writer) error {
+	return markdownReportTemplate.Execute(out, r)
+}
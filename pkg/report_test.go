@@ -0,0 +1,89 @@
+package model
+
+import (
+	"bytes"
+	"crypto/tls"
+	"strings"
+	"testing"
+)
+
+func TestBuildReport(t *testing.T) {
+	modern := testCapability([]uint16{tls.VersionTLS13, tls.VersionTLS12}, []uint16{0x1301, 0xc02f}, []tls.CurveID{tls.X25519}, nil)
+	modern.SupportedProtos = []string{"h2", "http/1.1"}
+	variant := testCapability([]uint16{tls.VersionTLS12}, []uint16{0xc02f}, []tls.CurveID{tls.X25519}, nil)
+	legacy := testCapability([]uint16{tls.VersionTLS10}, []uint16{0x0005, 0x002f}, nil, nil)
+	chrome := ClientDescription{Browser: "Chrome", BrowserVersion: "80.0", OS: "Linux"}
+	ie := ClientDescription{Browser: "IE", BrowserVersion: "8.0", OS: "Windows XP | <script>"}
+	report := BuildReport([]TLSClientCapability{
+		{ClientDescription: chrome, Capability: modern},
+		{ClientDescription: chrome, Capability: variant},
+		{ClientDescription: chrome, Capability: modern},
+		{ClientDescription: ie, Capability: legacy},
+	})
+
+	if report.Records != 4 || len(report.Rows) != 2 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	c, i := report.Rows[0], report.Rows[1]
+	if c.ClientDescription != chrome || c.Records != 3 || c.Variants != 2 || c.MaxVersion != "TLS v1.3" || !c.AEAD ||
+		!c.ForwardSecrecy || !c.H2 || len(c.TLS13Ciphers) != 1 || c.Grade != "A" || !c.SupportsTLS13() {
+		t.Errorf("Unexpected Chrome row %+v", c)
+	}
+	if i.MaxVersion != "TLS v1.0" || i.AEAD || i.ForwardSecrecy || i.H2 || i.Grade != "F" {
+		t.Errorf("Unexpected IE row %+v", i)
+	}
+
+	md := bytes.Buffer{}
+	if err := report.WriteMarkdown(&md); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "| Chrome | 80.0 | Linux | 3 | TLS v1.3 | ✓ | ✓ | TLS_AES_128_GCM_SHA256 | ✓ | A |") ||
+		!strings.Contains(md.String(), "Windows XP \\| <script>") {
+		t.Errorf("Unexpected Markdown report\n%s", md.String())
+	}
+
+	html := bytes.Buffer{}
+	if err := report.WriteHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(html.String(), "| <script>") || !strings.Contains(html.String(), `data-tls13="true"`) {
+		t.Errorf("Unexpected HTML report\n%s", html.String())
+	}
+}
+
+func TestDetailsDoNotSplitClients(t *testing.T) {
+	wow64, err := ParseClientDescription("Mozilla/5.0 (Windows NT 6.1; WOW64; rv:60.0) Gecko/20100101 Firefox/60.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	win64, err := ParseClientDescription("Mozilla/5.0 (Windows NT 6.1; Win64; x64; rv:60.0) Gecko/20100101 Firefox/60.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wow64 == win64 {
+		t.Fatalf("Expects the architectures to differ, got %+v", wow64)
+	}
+	firefox := ClientDescription{Browser: "Firefox", BrowserVersion: "60.0", OS: "Windows 7"}
+	capability := testCapability([]uint16{tls.VersionTLS12}, []uint16{0xc02f}, []tls.CurveID{tls.X25519}, nil)
+	caps := []TLSClientCapability{{ClientDescription: wow64, Capability: capability}, {ClientDescription: win64, Capability: capability}}
+
+	if rows := BuildReport(caps).Rows; len(rows) != 1 || rows[0].ClientDescription != firefox || rows[0].Records != 2 {
+		t.Errorf("Expects one report row of %+v, got %+v", firefox, rows)
+	}
+	compat := SummariseCompatibility(SimulateClients(Recommend(caps, RecommendOptions{}).Policy, caps))
+	if len(compat) != 1 || compat[0].ClientDescription != firefox || compat[0].Compatible != 2 {
+		t.Errorf("Expects one compatibility row of %+v, got %+v", firefox, compat)
+	}
+	for _, c := range Recommend(caps, RecommendOptions{}).Concessions {
+		if len(c.Clients) != 1 || c.Clients[0] != (ClientCount{ClientDescription: firefox, Records: 2}) {
+			t.Errorf("Expects one client forcing %s, got %+v", c.Setting, c.Clients)
+		}
+	}
+	if id := NewClassifier(caps).Identify(&capability.ClientHelloInfo); len(id.Candidates) != 1 || id.Candidates[0].ClientDescription != firefox {
+		t.Errorf("Expects one candidate of %+v, got %+v", firefox, id.Candidates)
+	}
+	diff := DiffCapabilities(TLSCapabilities{Capabilities: caps[:1]}, TLSCapabilities{Capabilities: caps[1:]})
+	if len(diff.NewClients) != 0 || len(diff.RemovedClients) != 0 {
+		t.Errorf("Expects no new or removed clients, got %+v and %+v", diff.NewClients, diff.RemovedClients)
+	}
+}
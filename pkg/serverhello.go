@@ -0,0 +1,113 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+var (
+	errMalformedServerHello = errors.New("Malformed ServerHello")
+
+	//helloRetryRequestRandom is the special random value that marks a ServerHello as a HelloRetryRequest, see RFC 8446 section 4.1.3
+	helloRetryRequestRandom = []byte{
+		0xCF, 0x21, 0xAD, 0x74, 0xE5, 0x9A, 0x61, 0x11, 0xBE, 0x1D, 0x8C, 0x02, 0x1E, 0x65, 0xB8, 0x91,
+		0xC2, 0xA2, 0x11, 0x16, 0x7A, 0xBB, 0x8C, 0x5E, 0x07, 0x9E, 0x09, 0xE2, 0xC8, 0xA8, 0x33, 0x9C,
+	}
+)
+
+//ServerHello is a TLS ServerHello (or HelloRetryRequest) as it appeared on the wire
+type ServerHello struct {
+	Raw               []byte //the TLS record(s) that carried the ServerHello
+	RecordVersion     uint16
+	Version           uint16
+	Random            []byte
+	SessionID         []byte
+	CipherSuite       uint16
+	CompressionMethod uint8
+	Extensions        []Extension
+	SupportedVersion  uint16
+	KeyShareGroup     uint16
+	ALPNProtocol      string
+	HelloRetryRequest bool
+}
+
+//ExtensionTypes returns the extension types in the order they appear in the ServerHello
+func (s *ServerHello) ExtensionTypes() (out []uint16) {
+	for _, e := range s.Extensions {
+		out = append(out, e.Type)
+	}
+	return
+}
+
+//NegotiatedVersion is the protocol version selected by the server, taking the supported_versions extension into account
+func (s *ServerHello) NegotiatedVersion() uint16 {
+	if s.SupportedVersion != 0 {
+		return s.SupportedVersion
+	}
+	return s.Version
+}
+
+//ParseServerHello decodes the ServerHello carried in the TLS record(s) at the start of data.
+//Any bytes following the ServerHello are ignored. io.ErrUnexpectedEOF is returned if data ends before the ServerHello is complete
+func ParseServerHello(data []byte) (*ServerHello, error) {
+	body, recordVersion, consumed, err := readHandshakeMessage(data, handshakeTypeServerHello)
+	if err != nil {
+		return nil, err
+	}
+	hello := ServerHello{
+		RecordVersion: recordVersion,
+		Raw:           append([]byte{}, data[:consumed]...),
+	}
+	if err := hello.unmarshal(body); err != nil {
+		return nil, err
+	}
+	return &hello, nil
+}
+
+func (s *ServerHello) unmarshal(body []byte) error {
+	str := cryptobyte.String(body)
+	var random, sessionID []byte
+	if !str.ReadUint16(&s.Version) || !str.ReadBytes(&random, 32) ||
+		!readUint8LengthPrefixedBytes(&str, &sessionID) ||
+		!str.ReadUint16(&s.CipherSuite) || !str.ReadUint8(&s.CompressionMethod) {
+		return errMalformedServerHello
+	}
+	s.Random = append([]byte{}, random...)
+	s.SessionID = append([]byte{}, sessionID...)
+	s.HelloRetryRequest = bytes.Equal(s.Random, helloRetryRequestRandom)
+	if str.Empty() {
+		return nil
+	}
+
+	var extensions cryptobyte.String
+	if !str.ReadUint16LengthPrefixed(&extensions) || !str.Empty() {
+		return errMalformedServerHello
+	}
+	for !extensions.Empty() {
+		var extType uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&extType) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return errMalformedServerHello
+		}
+		s.Extensions = append(s.Extensions, Extension{Type: extType, Data: append([]byte{}, data...)})
+		ok := true
+		switch extType {
+		case extensionSupportedVersions:
+			ok = data.ReadUint16(&s.SupportedVersion)
+		case extensionKeyShare:
+			ok = data.ReadUint16(&s.KeyShareGroup)
+		case extensionALPN:
+			var protos cryptobyte.String
+			var proto []byte
+			if ok = data.ReadUint16LengthPrefixed(&protos) && readUint8LengthPrefixedBytes(&protos, &proto); ok {
+				s.ALPNProtocol = string(proto)
+			}
+		}
+		if !ok {
+			return errMalformedServerHello
+		}
+	}
+	return nil
+}
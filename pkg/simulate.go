@@ -0,0 +1,480 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	tlsdefs "github.com/adedayo/tls-definitions"
+)
+
+//Certificate key types of a ServerPolicy
+const (
+	KeyTypeRSA     = "RSA"
+	KeyTypeECDSA   = "ECDSA"
+	KeyTypeEd25519 = "Ed25519"
+)
+
+//ServerPolicy is the TLS configuration of a server, against which recorded clients are simulated
+type ServerPolicy struct {
+	Versions                 []uint16              //the enabled protocol versions
+	CipherSuites             []uint16              //the enabled cipher suites, in order of preference
+	Curves                   []tls.CurveID         //the enabled key exchange groups in order of preference, empty for all
+	SignatureSchemes         []tls.SignatureScheme //the signature schemes the certificate may be used with, empty for all
+	ALPN                     []string              //the application protocols, in order of preference
+	CertificateKeyType       string                //RSA, ECDSA or Ed25519
+	PreferServerCipherSuites bool                  //pick the first suite of CipherSuites the client offers, rather than the client's first choice
+}
+
+//SimulationResult is the outcome of a simulated handshake between a client and a ServerPolicy
+type SimulationResult struct {
+	Version           uint16
+	CipherSuite       uint16
+	Group             tls.CurveID //the key exchange group, zero when the key exchange uses none or it is not known
+	SignatureScheme   tls.SignatureScheme
+	ALPN              string
+	HelloRetryRequest bool   //the server asked for a key share of Group, only reported by handshakes with a real server
+	Failure           string //why the handshake fails, empty if it succeeds
+}
+
+//OK reports whether the handshake succeeds
+func (r SimulationResult) OK() bool {
+	return r.Failure == ""
+}
+
+func (r SimulationResult) String() string {
+	if !r.OK() {
+		return r.Failure
+	}
+	s := fmt.Sprintf("%s %s", versionName(r.Version), cipherSuiteName(r.CipherSuite))
+	if r.Group != 0 {
+		s += " " + curveName(r.Group)
+	}
+	if r.ALPN != "" {
+		s += " " + r.ALPN
+	}
+	return s
+}
+
+//less orders results by their description, then by the fields it leaves out, so that distinct results never tie
+func (r SimulationResult) less(o SimulationResult) bool {
+	if a, b := r.String(), o.String(); a != b {
+		return a < b
+	}
+	switch {
+	case r.Version != o.Version:
+		return r.Version < o.Version
+	case r.CipherSuite != o.CipherSuite:
+		return r.CipherSuite < o.CipherSuite
+	case r.Group != o.Group:
+		return r.Group < o.Group
+	case r.SignatureScheme != o.SignatureScheme:
+		return r.SignatureScheme < o.SignatureScheme
+	case r.ALPN != o.ALPN:
+		return r.ALPN < o.ALPN
+	}
+	return !r.HelloRetryRequest && o.HelloRetryRequest
+}
+
+//LoadServerPolicy reads a JSON ServerPolicy file
+func LoadServerPolicy(file string) (policy ServerPolicy, err error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &policy)
+	return
+}
+
+//MarshalJSON serialises ServerPolicy to JSON, using the names of versions, cipher suites, curves and signature schemes
+func (p ServerPolicy) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"Versions":                 names(tlsdefs.TLSVersionMap, p.Versions),
+		"CipherSuites":             names(tlsdefs.CipherSuiteMap, p.CipherSuites),
+		"Curves":                   names(tlsdefs.SupportedGroups, curveIDs(p.Curves)),
+		"SignatureSchemes":         names(tlsdefs.SignatureSchemes, schemeIDs(p.SignatureSchemes)),
+		"ALPN":                     p.ALPN,
+		"CertificateKeyType":       p.CertificateKeyType,
+		"PreferServerCipherSuites": p.PreferServerCipherSuites,
+	}
+	return json.Marshal(m)
+}
+
+//UnmarshalJSON deserialises ServerPolicy from JSON. Versions, cipher suites, curves and signature schemes are given by name,
+//e.g. "TLS v1.2", "TLS_AES_128_GCM_SHA256", "x25519" and "PSSWithSHA256", or as hex values
+func (p *ServerPolicy) UnmarshalJSON(data []byte) error {
+	m := map[string]interface{}{}
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+	p.CertificateKeyType = KeyTypeRSA
+	for k, v := range m {
+		switch k {
+		case "Versions":
+			if p.Versions, err = parseNames(k, v, tlsdefs.TLSVersionMap); err != nil {
+				return err
+			}
+		case "CipherSuites":
+			if p.CipherSuites, err = parseNames(k, v, tlsdefs.CipherSuiteMap); err != nil {
+				return err
+			}
+		case "Curves":
+			ids, err := parseNames(k, v, tlsdefs.SupportedGroups)
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				p.Curves = append(p.Curves, tls.CurveID(id))
+			}
+		case "SignatureSchemes":
+			ids, err := parseNames(k, v, tlsdefs.SignatureSchemes)
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				p.SignatureSchemes = append(p.SignatureSchemes, tls.SignatureScheme(id))
+			}
+		case "ALPN":
+			protos, ok := v.([]interface{})
+			if !ok && v != nil {
+				return fmt.Errorf("Expects a string slice %s, but got %#v", k, v)
+			}
+			for _, p2 := range protos {
+				if proto, ok := p2.(string); ok {
+					p.ALPN = append(p.ALPN, proto)
+				}
+			}
+		case "CertificateKeyType":
+			switch kt, _ := v.(string); kt {
+			case KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519:
+				p.CertificateKeyType = kt
+			default:
+				return fmt.Errorf("Expects a certificate key type of %s, %s or %s, but got %#v", KeyTypeRSA, KeyTypeECDSA, KeyTypeEd25519, v)
+			}
+		case "PreferServerCipherSuites":
+			if prefer, ok := v.(bool); ok {
+				p.PreferServerCipherSuites = prefer
+			}
+		}
+	}
+	if len(p.Versions) == 0 || len(p.CipherSuites) == 0 {
+		return fmt.Errorf("Expects a policy with at least one version and cipher suite")
+	}
+	return nil
+}
+
+//parseNames maps a JSON list of names, or hex values, to their IDs in defs
+func parseNames(k string, v interface{}, defs map[uint16]string) (ids []uint16, err error) {
+	if v == nil {
+		return
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return ids, fmt.Errorf("Expects a string slice %s, but got %#v", k, v)
+	}
+	for _, x := range list {
+		name, ok := x.(string)
+		if !ok {
+			return ids, fmt.Errorf("Expects a string, but got %#v", x)
+		}
+		if id, err := strconv.ParseUint(name, 0, 16); err == nil {
+			ids = append(ids, uint16(id))
+			continue
+		}
+		id, found := lookupName(defs, name)
+		if !found {
+			return ids, fmt.Errorf("Unknown %s %s", k, name)
+		}
+		ids = append(ids, id)
+	}
+	return
+}
+
+func lookupName(defs map[uint16]string, name string) (uint16, bool) {
+	for id, n := range defs {
+		if strings.EqualFold(n, name) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func names(defs map[uint16]string, ids []uint16) (out []string) {
+	for _, id := range ids {
+		if name, ok := defs[id]; ok {
+			out = append(out, name)
+		} else {
+			out = append(out, hex([]uint16{id})[0])
+		}
+	}
+	return
+}
+
+func curveIDs(curves []tls.CurveID) (ids []uint16) {
+	for _, c := range curves {
+		ids = append(ids, uint16(c))
+	}
+	return
+}
+
+func schemeIDs(schemes []tls.SignatureScheme) (ids []uint16) {
+	for _, s := range schemes {
+		ids = append(ids, uint16(s))
+	}
+	return
+}
+
+func cipherSuiteName(id uint16) string {
+	return names(tlsdefs.CipherSuiteMap, []uint16{id})[0]
+}
+
+func curveName(c tls.CurveID) string {
+	return names(tlsdefs.SupportedGroups, []uint16{uint16(c)})[0]
+}
+
+//Simulate works out what a client with the capability would negotiate with a server configured with the policy, or why it would fail to connect
+func Simulate(policy ServerPolicy, cap TLSCapability) (r SimulationResult) {
+	for _, v := range policy.Versions {
+		if v > r.Version && containsUint16(cap.SupportedVersions, v) {
+			r.Version = v
+		}
+	}
+	if r.Version == 0 {
+		r.Failure = "No common protocol version"
+		return
+	}
+	tls13 := r.Version == tls.VersionTLS13
+
+	group := tls.CurveID(0)
+	for _, c := range policy.Curves {
+		if containsCurve(cap.SupportedCurves, c) {
+			group = c
+			break
+		}
+	}
+	if len(policy.Curves) == 0 {
+		for _, c := range cap.SupportedCurves {
+			if _, known := tlsdefs.SupportedGroups[uint16(c)]; known && !IsGREASE(uint16(c)) {
+				group = c
+				break
+			}
+		}
+	}
+	if tls13 && group == 0 {
+		r.Failure = "No common key exchange group"
+		return
+	}
+
+	usable := func(id uint16) bool {
+		cs, ok := ParseCipherSuite(id)
+		if !ok || cs.TLS13 != tls13 {
+			return false
+		}
+		if !tls13 {
+			if r.Version < tls.VersionTLS12 && (cs.AEAD() || cs.MAC == "SHA256" || cs.MAC == "SHA384") {
+				return false
+			}
+			if cs.Authentication != "anon" && cs.Authentication != certificateAuthentication(policy.CertificateKeyType) {
+				return false
+			}
+			if cs.KeyExchange == "ECDHE" && group == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	first, second := cap.CipherSuites, policy.CipherSuites
+	if policy.PreferServerCipherSuites {
+		first, second = second, first
+	}
+	for _, id := range first {
+		if containsUint16(second, id) && usable(id) {
+			r.CipherSuite = id
+			break
+		}
+	}
+	if r.CipherSuite == 0 {
+		r.Failure = "No common cipher suite"
+		return
+	}
+	if cs, _ := ParseCipherSuite(r.CipherSuite); tls13 || cs.KeyExchange == "ECDHE" {
+		r.Group = group
+	}
+
+	if r.Version >= tls.VersionTLS12 {
+		if cs, _ := ParseCipherSuite(r.CipherSuite); cs.Authentication != "anon" {
+			if len(cap.SignatureSchemes) == 0 && !tls13 {
+				//a TLS 1.2 client without signature_algorithms implies SHA-1
+				r.SignatureScheme = legacySignatureScheme(policy.CertificateKeyType)
+			} else {
+				for _, s := range cap.SignatureSchemes {
+					if signatureSchemeUsable(s, policy.CertificateKeyType, tls13) &&
+						(len(policy.SignatureSchemes) == 0 || containsScheme(policy.SignatureSchemes, s)) {
+						r.SignatureScheme = s
+						break
+					}
+				}
+				if r.SignatureScheme == 0 {
+					r.Failure = "No common signature scheme"
+					return
+				}
+			}
+		}
+	}
+
+	if len(policy.ALPN) > 0 && len(cap.SupportedProtos) > 0 {
+		for _, proto := range policy.ALPN {
+			if containsString(cap.SupportedProtos, proto) {
+				r.ALPN = proto
+				break
+			}
+		}
+		if r.ALPN == "" {
+			r.Failure = "No common application protocol"
+			return
+		}
+	}
+	return
+}
+
+func certificateAuthentication(keyType string) string {
+	if keyType == KeyTypeRSA {
+		return "RSA"
+	}
+	return "ECDSA"
+}
+
+func legacySignatureScheme(keyType string) tls.SignatureScheme {
+	if keyType == KeyTypeRSA {
+		return tls.PKCS1WithSHA1
+	}
+	return tls.ECDSAWithSHA1
+}
+
+//signatureSchemeUsable reports whether a certificate of the key type can sign with the scheme
+func signatureSchemeUsable(s tls.SignatureScheme, keyType string, tls13 bool) bool {
+	switch keyType {
+	case KeyTypeRSA:
+		switch s {
+		case tls.PSSWithSHA256, tls.PSSWithSHA384, tls.PSSWithSHA512:
+			return true
+		}
+		return !tls13 && s&0xff == 0x01
+	case KeyTypeECDSA:
+		switch s {
+		case tls.ECDSAWithP256AndSHA256, tls.ECDSAWithP384AndSHA384, tls.ECDSAWithP521AndSHA512:
+			return true
+		}
+		return !tls13 && s&0xff == 0x03
+	case KeyTypeEd25519:
+		return s == tls.Ed25519
+	}
+	return false
+}
+
+//ClientSimulation is the simulated handshake of a recorded client
+type ClientSimulation struct {
+	ClientDescription ClientDescription
+	Agent             string
+	Result            SimulationResult
+}
+
+//ClientCompatibility summarises the simulated handshakes of the records of a browser, its version and operating system
+type ClientCompatibility struct {
+	ClientDescription ClientDescription
+	Records           int
+	Compatible        int
+	Result            SimulationResult //the most common result, successful ones first
+}
+
+//SimulateClients simulates the handshakes of recorded clients with a server configured with the policy
+func SimulateClients(policy ServerPolicy, clients []TLSClientCapability) (out []ClientSimulation) {
+	for _, c := range clients {
+		out = append(out, ClientSimulation{
+			ClientDescription: c.ClientDescription,
+			Agent:             c.Agent,
+			Result:            Simulate(policy, c.Capability),
+		})
+	}
+	return
+}
+
+//SummariseCompatibility groups simulations by browser, version and operating system, sorted in that order
+func SummariseCompatibility(sims []ClientSimulation) (out []ClientCompatibility) {
+	type group struct {
+		compat  ClientCompatibility
+		results map[SimulationResult]int
+	}
+	groups := map[ClientDescription]*group{}
+	for _, s := range sims {
+		desc := s.ClientDescription.aggregate()
+		g, ok := groups[desc]
+		if !ok {
+			g = &group{compat: ClientCompatibility{ClientDescription: desc}, results: map[SimulationResult]int{}}
+			groups[desc] = g
+		}
+		g.compat.Records++
+		if s.Result.OK() {
+			g.compat.Compatible++
+		}
+		g.results[s.Result]++
+	}
+	for _, g := range groups {
+		best := -1
+		for r, n := range g.results {
+			if r.OK() != (g.compat.Compatible > 0) {
+				continue
+			}
+			if n > best || n == best && r.less(g.compat.Result) {
+				best = n
+				g.compat.Result = r
+			}
+		}
+		out = append(out, g.compat)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ClientDescription.less(out[j].ClientDescription)
+	})
+	return
+}
+
+func containsUint16(list []uint16, x uint16) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCurve(list []tls.CurveID, x tls.CurveID) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func containsScheme(list []tls.SignatureScheme, x tls.SignatureScheme) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, x string) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
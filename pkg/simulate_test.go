@@ -0,0 +1,115 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"testing"
+)
+
+const testPolicy = `{
+ "Versions": ["TLS v1.2", "TLS v1.3"],
+ "CipherSuites": ["TLS_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "0xc013"],
+ "Curves": ["x25519", "secp256r1"],
+ "ALPN": ["h2", "http/1.1"],
+ "PreferServerCipherSuites": true
+}`
+
+func testCapability(versions, ciphers []uint16, curves []tls.CurveID, schemes []tls.SignatureScheme) TLSCapability {
+	cap := TLSCapability{}
+	cap.SupportedVersions = versions
+	cap.CipherSuites = ciphers
+	cap.SupportedCurves = curves
+	cap.SignatureSchemes = schemes
+	return cap
+}
+
+func TestServerPolicyJSON(t *testing.T) {
+	policy := ServerPolicy{}
+	if err := json.Unmarshal([]byte(testPolicy), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.CertificateKeyType != KeyTypeRSA || len(policy.CipherSuites) != 4 || policy.CipherSuites[3] != 0xc013 ||
+		policy.Curves[0] != tls.X25519 || !policy.PreferServerCipherSuites {
+		t.Errorf("Unexpected policy %+v", policy)
+	}
+	js, err := json.Marshal(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy2 := ServerPolicy{}
+	if err := json.Unmarshal(js, &policy2); err != nil || len(policy2.CipherSuites) != 4 || policy2.CipherSuites[3] != 0xc013 {
+		t.Errorf("Policy did not round trip %s %v", js, err)
+	}
+	for _, bad := range []string{`{"Versions": ["TLS v1.2"]}`, `{"Versions": ["TLS v1.2"], "CipherSuites": ["TLS_MADE_UP"]}`} {
+		if err := json.Unmarshal([]byte(bad), &ServerPolicy{}); err == nil {
+			t.Errorf("Expected an error for %s", bad)
+		}
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	policy := ServerPolicy{}
+	if err := json.Unmarshal([]byte(testPolicy), &policy); err != nil {
+		t.Fatal(err)
+	}
+	modernSchemes := []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256, tls.PSSWithSHA256, tls.PKCS1WithSHA256}
+	cases := []struct {
+		name   string
+		cap    TLSCapability
+		result SimulationResult
+	}{
+		{"TLS 1.3", testCapability([]uint16{0x1a1a, tls.VersionTLS13, tls.VersionTLS12}, []uint16{0x1a1a, 0x1302, 0x1301, 0xc02f}, []tls.CurveID{tls.CurveP256, tls.X25519}, modernSchemes),
+			SimulationResult{Version: tls.VersionTLS13, CipherSuite: 0x1301, Group: tls.X25519, SignatureScheme: tls.PSSWithSHA256}},
+		{"server preference", testCapability([]uint16{tls.VersionTLS12}, []uint16{0xc013, 0xc02f, 0xc030}, []tls.CurveID{tls.CurveP256}, modernSchemes),
+			SimulationResult{Version: tls.VersionTLS12, CipherSuite: 0xc030, Group: tls.CurveP256, SignatureScheme: tls.PSSWithSHA256}},
+		{"no signature_algorithms", testCapability([]uint16{tls.VersionTLS12}, []uint16{0xc013}, []tls.CurveID{tls.CurveP256}, nil),
+			SimulationResult{Version: tls.VersionTLS12, CipherSuite: 0xc013, Group: tls.CurveP256, SignatureScheme: tls.PKCS1WithSHA1}},
+		{"old version", testCapability([]uint16{tls.VersionTLS10}, []uint16{0xc013}, []tls.CurveID{tls.CurveP256}, nil),
+			SimulationResult{Failure: "No common protocol version"}},
+		{"no curves", testCapability([]uint16{tls.VersionTLS12}, []uint16{0xc013, 0x002f}, nil, modernSchemes),
+			SimulationResult{Version: tls.VersionTLS12, Failure: "No common cipher suite"}},
+		{"ECDSA only", testCapability([]uint16{tls.VersionTLS12}, []uint16{0xc02f}, []tls.CurveID{tls.X25519}, []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256}),
+			SimulationResult{Version: tls.VersionTLS12, CipherSuite: 0xc02f, Group: tls.X25519, Failure: "No common signature scheme"}},
+	}
+	for _, c := range cases {
+		if r := Simulate(policy, c.cap); r != c.result {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.result, r)
+		}
+	}
+
+	cap := testCapability([]uint16{tls.VersionTLS13}, []uint16{0x1301}, []tls.CurveID{tls.X25519}, modernSchemes)
+	cap.SupportedProtos = []string{"spdy/3"}
+	if r := Simulate(policy, cap); r.Failure != "No common application protocol" {
+		t.Errorf("Expected no common application protocol, got %+v", r)
+	}
+	cap.SupportedProtos = []string{"http/1.1", "h2"}
+	if r := Simulate(policy, cap); !r.OK() || r.ALPN != "h2" {
+		t.Errorf("Expected h2, got %+v", r)
+	}
+}
+
+func TestSummariseCompatibility(t *testing.T) {
+	firefox := ClientDescription{Browser: "Firefox", BrowserVersion: "60.0", OS: "Linux"}
+	chrome := ClientDescription{Browser: "Chrome", BrowserVersion: "70.0", OS: "Linux"}
+	ok := SimulationResult{Version: tls.VersionTLS12, CipherSuite: 0xc02f}
+	sims := []ClientSimulation{
+		{ClientDescription: firefox, Result: SimulationResult{Failure: "No common cipher suite"}},
+		{ClientDescription: firefox, Result: ok},
+		{ClientDescription: chrome, Result: SimulationResult{Failure: "No common protocol version"}},
+	}
+	summary := SummariseCompatibility(sims)
+	if len(summary) != 2 || summary[0].ClientDescription != chrome || summary[0].Compatible != 0 ||
+		summary[1].Records != 2 || summary[1].Compatible != 1 || summary[1].Result != ok {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+
+	//results described alike but for their signature scheme tie on the full result, not on the order of a map
+	pss, ecdsa := ok, ok
+	pss.SignatureScheme, ecdsa.SignatureScheme = tls.PSSWithSHA256, tls.ECDSAWithP256AndSHA256
+	for i := 0; i < 20; i++ {
+		summary := SummariseCompatibility([]ClientSimulation{{ClientDescription: chrome, Result: pss}, {ClientDescription: chrome, Result: ecdsa}})
+		if len(summary) != 1 || summary[0].Result != ecdsa {
+			t.Fatalf("Expects the lower signature scheme to break the tie, got %+v", summary)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package model
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+//Codes of the reasons given by DetectSpoofing
+const (
+	SpoofingTLS13            = "TLS13_FROM_OLD_BROWSER"
+	SpoofingMissingGREASE    = "MISSING_GREASE"
+	SpoofingUnexpectedGREASE = "UNEXPECTED_GREASE"
+	SpoofingOtherBrowsers    = "FINGERPRINT_OF_OTHER_BROWSERS"
+)
+
+//minSpoofingEvidence is the number of records of a fingerprint, all from other browsers, needed to contradict a claimed browser
+const minSpoofingEvidence = 3
+
+//tls13Since is the first major version of each engine to offer TLS 1.3 as standardised, 0 for engines that never did.
+//Engines are keyed rather than browsers, as a brand may ship on several: Edge on EdgeHTML, Blink and, on iOS, WebKit.
+//WebKit is left out, as the TLS of the iOS brands and Safari comes from the operating system rather than the browser
+var tls13Since = map[string]int{
+	"Blink":    70,
+	"Gecko":    63,
+	"Trident":  0,
+	"EdgeHTML": 0,
+}
+
+//greaseSince is the first major version of each engine to send GREASE values, 0 for engines that never do
+var greaseSince = map[string]int{
+	"Blink":   55,
+	"Gecko":   0,
+	"Trident": 0,
+}
+
+//SpoofingReason is why a TLS fingerprint contradicts the browser claimed by a user agent
+type SpoofingReason struct {
+	Code        string
+	Description string
+}
+
+//SpoofingVerdict tells whether the TLS fingerprint of a capture is consistent with the browser its user agent claims.
+//Bots commonly claim to be a browser over the TLS stack of a programming language
+type SpoofingVerdict struct {
+	Mismatch bool   //the fingerprint contradicts the claimed browser
+	Claimed  string //the browser and version claimed, e.g. Chrome 75.0, empty if the agent is not recognised
+	Reasons  []SpoofingReason
+}
+
+//DetectSpoofing checks the ClientHello of a capture against what the engine of the browser claimed by its user agent and
+//Client Hints is known to send: TLS 1.3 and GREASE values (RFC 8701) only from the versions that support them, and never
+//from engines that do not. If a classifier is given, a fingerprint recorded from other browsers only also contradicts the claim.
+//The classifier must not be trained on captures this flags, see Classifier.Learn, or spoofers would become the evidence for their claims
+func DetectSpoofing(info TLSInfoAndAgent, classifier *Classifier) (verdict SpoofingVerdict) {
+	d := getClientDescription(info.Agent, info.ClientHints)
+	if d.Browser == "" || (info.HelloInfo == nil && info.ClientHello == nil) {
+		return
+	}
+	verdict.Claimed = strings.TrimSpace(d.Browser + " " + d.BrowserVersion)
+	add := func(code, format string, args ...interface{}) {
+		verdict.Mismatch = true
+		verdict.Reasons = append(verdict.Reasons, SpoofingReason{Code: code, Description: fmt.Sprintf(format, args...)})
+	}
+
+	var f helloFeatures
+	grease := false
+	if info.ClientHello != nil {
+		f = clientHelloFeatures(info.ClientHello, false)
+		grease = hasGREASE(info.ClientHello.CipherSuites, info.ClientHello.ExtensionTypes(), info.ClientHello.SupportedGroups,
+			info.ClientHello.SupportedVersions)
+	} else {
+		f = helloInfoFeatures(info.HelloInfo, false)
+		grease = hasGREASE(info.HelloInfo.CipherSuites, curveIDs(info.HelloInfo.SupportedCurves), info.HelloInfo.SupportedVersions)
+	}
+	tls13 := containsString(f[0], fmt.Sprint(tls.VersionTLS13))
+
+	engine, version := d.Engine, d.EngineVersion
+	if engine == "Blink" && d.ChromiumVersion != "" {
+		version = d.ChromiumVersion //which Client Hints may give beyond a frozen user agent
+	}
+	major := -1
+	if v, err := ParseVersion(version); err == nil {
+		major = v.Parts[0]
+	}
+	if since, known := tls13Since[engine]; known && tls13 && (since == 0 || (major >= 0 && major < since)) {
+		add(SpoofingTLS13, "Offers TLS 1.3, which %s", supportedSince(engine, since))
+	}
+	if since, known := greaseSince[engine]; known && major >= 0 {
+		switch {
+		case grease && (since == 0 || major < since):
+			add(SpoofingUnexpectedGREASE, "Sends GREASE values, which %s", supportedSince(engine, since))
+		case !grease && since > 0 && major >= since:
+			add(SpoofingMissingGREASE, "Sends no GREASE values, which %s %d and later always send", engine, since)
+		}
+	}
+
+	if classifier != nil {
+		id := classifier.identify(f)
+		if id.Exact && id.Records >= minSpoofingEvidence {
+			others, recorded := []string{}, false
+			for _, c := range id.Candidates {
+				recorded = recorded || strings.EqualFold(c.ClientDescription.Browser, d.Browser)
+				if !containsString(others, c.ClientDescription.Browser) {
+					others = append(others, c.ClientDescription.Browser)
+				}
+			}
+			if !recorded {
+				add(SpoofingOtherBrowsers, "The fingerprint was recorded %d times, only from %s", id.Records, strings.Join(others, ", "))
+			}
+		}
+	}
+	return
+}
+
+//supportedSince completes a sentence about a feature the engine of the claimed browser does not support
+func supportedSince(engine string, since int) string {
+	if since == 0 {
+		return engine + " does not support"
+	}
+	return fmt.Sprintf("%s supports from version %d", engine, since)
+}
+
+func hasGREASE(lists ...[]uint16) bool {
+	for _, list := range lists {
+		for _, v := range list {
+			if IsGREASE(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"testing"
+)
+
+const (
+	chrome75Agent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.100 Safari/537.36"
+	chrome40Agent = "Mozilla/5.0 (Windows NT 6.1; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/40.0.2214.93 Safari/537.36"
+	ie9Agent      = "Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.1; Trident/5.0)"
+)
+
+// goHello is a ClientHello in the style of Go's crypto/tls: TLS 1.3 without GREASE
+var goHello = tls.ClientHelloInfo{
+	CipherSuites:      []uint16{0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0x1301, 0x1302, 0x1303},
+	SupportedCurves:   []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521},
+	SupportedPoints:   []uint8{0},
+	SignatureSchemes:  []tls.SignatureScheme{tls.PSSWithSHA256, tls.ECDSAWithP256AndSHA256, tls.Ed25519},
+	SupportedVersions: []uint16{tls.VersionTLS13, tls.VersionTLS12},
+}
+
+func reasonCodes(v SpoofingVerdict) (codes []string) {
+	for _, r := range v.Reasons {
+		codes = append(codes, r.Code)
+	}
+	return
+}
+
+func TestDetectSpoofing(t *testing.T) {
+	hello := goHello
+	v := DetectSpoofing(TLSInfoAndAgent{Agent: chrome75Agent, HelloInfo: &hello}, nil)
+	if !v.Mismatch || v.Claimed != "Chrome 75.0" || len(v.Reasons) != 1 || v.Reasons[0].Code != SpoofingMissingGREASE {
+		t.Errorf("Expects Chrome 75 without GREASE to be flagged, got %+v", v)
+	}
+	v = DetectSpoofing(TLSInfoAndAgent{Agent: ie9Agent, HelloInfo: &hello}, nil)
+	if codes := reasonCodes(v); !v.Mismatch || len(codes) != 1 || codes[0] != SpoofingTLS13 {
+		t.Errorf("Expects IE 9 offering TLS 1.3 to be flagged, got %+v", v)
+	}
+
+	greased := goHello
+	greased.CipherSuites = append([]uint16{0x2a2a}, greased.CipherSuites...)
+	if v := DetectSpoofing(TLSInfoAndAgent{Agent: chrome75Agent, HelloInfo: &greased}, nil); v.Mismatch {
+		t.Errorf("Expects Chrome 75 with GREASE and TLS 1.3 to be consistent, got %+v", v)
+	}
+	v = DetectSpoofing(TLSInfoAndAgent{Agent: chrome40Agent, HelloInfo: &greased}, nil)
+	if codes := reasonCodes(v); len(codes) != 2 || codes[0] != SpoofingTLS13 || codes[1] != SpoofingUnexpectedGREASE {
+		t.Errorf("Expects Chrome 40 with GREASE and TLS 1.3 to be flagged twice, got %+v", v)
+	}
+	if v := DetectSpoofing(TLSInfoAndAgent{Agent: "curl/7.64.1", HelloInfo: &hello}, nil); v.Mismatch || v.Claimed != "" {
+		t.Errorf("Expects no verdict for an unrecognised agent, got %+v", v)
+	}
+}
+
+func TestDetectSpoofingIOSBrands(t *testing.T) {
+	//the brands on iOS are WebKit with the operating system's TLS, whatever their version elsewhere
+	agents := map[string]string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 14_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) EdgiOS/46.3.13 Mobile/15E148 Safari/605.1.15":  "Edge 46.3",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 14_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/87.0.4280.77 Mobile/15E148 Safari/604.1": "Safari 604.1",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 14_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/31.0 Mobile/15E148 Safari/605.1.15":      "Safari 605.1.15",
+	}
+	greased := goHello
+	greased.CipherSuites = append([]uint16{0x2a2a}, greased.CipherSuites...)
+	for agent, claimed := range agents {
+		for _, hello := range []tls.ClientHelloInfo{goHello, greased} {
+			hello := hello
+			if v := DetectSpoofing(TLSInfoAndAgent{Agent: agent, HelloInfo: &hello}, nil); v.Mismatch || v.Claimed != claimed {
+				t.Errorf("Expects %s offering TLS 1.3 to be consistent, got %+v", agent, v)
+			}
+		}
+	}
+	edge18 := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.17763"
+	hello := goHello
+	if codes := reasonCodes(DetectSpoofing(TLSInfoAndAgent{Agent: edge18, HelloInfo: &hello}, nil)); len(codes) != 1 || codes[0] != SpoofingTLS13 {
+		t.Errorf("Expects EdgeHTML offering TLS 1.3 to be flagged, got %v", codes)
+	}
+}
+
+func TestDetectSpoofingWithClassifier(t *testing.T) {
+	legacy := tls.ClientHelloInfo{CipherSuites: []uint16{0xc013, 0x002f}, SupportedVersions: []uint16{tls.VersionTLS12, tls.VersionTLS11}}
+	clients := []TLSClientCapability{}
+	for i := 0; i < minSpoofingEvidence; i++ {
+		clients = append(clients, TLSClientCapability{
+			ClientDescription: ClientDescription{Browser: "Firefox", BrowserVersion: "30.0"},
+			Capability:        TLSCapability{ClientHelloInfo: legacy},
+		})
+	}
+	classifier := NewClassifier(clients)
+	v := DetectSpoofing(TLSInfoAndAgent{Agent: chrome40Agent, HelloInfo: &legacy}, classifier)
+	if codes := reasonCodes(v); len(codes) != 1 || codes[0] != SpoofingOtherBrowsers {
+		t.Errorf("Expects a fingerprint recorded only from Firefox to contradict Chrome, got %+v", v)
+	}
+	if v := DetectSpoofing(TLSInfoAndAgent{Agent: chrome40Agent, HelloInfo: &legacy}, NewClassifier(clients[1:])); v.Mismatch {
+		t.Errorf("Expects too few records not to contradict Chrome, got %+v", v)
+	}
+
+	//a spoofed capture learnt by the classifier does not become evidence for the browser it impersonates
+	spoofed := TLSInfoAndAgent{Agent: chrome40Agent, HelloInfo: &legacy, Spoofing: &v}
+	classifier.Learn(spoofed)
+	if codes := reasonCodes(DetectSpoofing(spoofed, classifier)); len(codes) != 1 || codes[0] != SpoofingOtherBrowsers {
+		t.Errorf("Expects the fingerprint still to contradict Chrome after a spoofed capture, got %v", codes)
+	}
+
+	info := TLSInfoAndAgent{Agent: chrome40Agent, HelloInfo: &legacy, Spoofing: &v}
+	js, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info2 := TLSInfoAndAgent{}
+	if err := json.Unmarshal(js, &info2); err != nil || info2.Spoofing == nil || info2.Spoofing.Reasons[0] != v.Reasons[0] {
+		t.Errorf("The verdict did not round trip %s %v", js, err)
+	}
+}
+
+func TestDetectSpoofingBrowserData(t *testing.T) {
+	infos, err := GetRawData("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flagged := 0
+	for _, info := range infos {
+		if DetectSpoofing(info, nil).Mismatch {
+			flagged++
+		}
+	}
+	//the recorded browsers are overwhelmingly genuine
+	if flagged*100 > len(infos) {
+		t.Errorf("Expects at most 1%% of the recorded browsers to be flagged, got %d of %d", flagged, len(infos))
+	}
+}
@@ -0,0 +1,265 @@
+package model
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	//JSONLinesStoreKind is the kind of store that appends JSON records, one per line, to browser-data.json
+	JSONLinesStoreKind = "jsonl"
+	//BoltStoreKind is the kind of store that keeps records in an embedded bbolt database, browser-data.db
+	BoltStoreKind = "bolt"
+)
+
+var recordsBucket = []byte("records")
+
+//Store persists captured TLS records
+type Store interface {
+	//Append adds a record to the store
+	Append(info TLSInfoAndAgent) error
+	//Iterate streams the records to fn in the order they were appended. It stops when the context is done,
+	//at the first error returned by fn, or once opts.Limit records have been read
+	Iterate(ctx context.Context, opts ReadOptions, fn func(TLSInfoAndAgent) error) error
+	//Query returns the records matching q
+	Query(ctx context.Context, q Query) ([]TLSInfoAndAgent, error)
+	//Count returns the number of records held
+	Count(ctx context.Context) (int, error)
+	//Close releases the resources held by the store
+	Close() error
+}
+
+//Query selects stored records. Empty fields match every record
+type Query struct {
+	Browser  string        //the browser name, e.g. Firefox
+	OS       string        //the operating system, e.g. Windows 10
+	Versions *VersionRange //the browsers or operating systems by version, e.g. Chrome >= 70 < 76, see ParseVersionRange
+	Since    time.Time     //records captured at or after this time. Records without a timestamp never match a time bound
+	Until    time.Time     //records captured before this time
+	//Fingerprint selects the records with this JA3, JA3 hash, JA4 or JA4H fingerprint, see Fingerprints.Matches
+	Fingerprint string
+}
+
+//Matches reports whether a record is selected by the query
+func (q Query) Matches(info TLSInfoAndAgent) bool {
+	if !q.Since.IsZero() && (info.Timestamp.IsZero() || info.Timestamp.Before(q.Since)) {
+		return false
+	}
+	if !q.Until.IsZero() && (info.Timestamp.IsZero() || !info.Timestamp.Before(q.Until)) {
+		return false
+	}
+	if q.Fingerprint != "" && !info.Fingerprints().Matches(q.Fingerprint) {
+		return false
+	}
+	if q.Browser != "" || q.OS != "" || q.Versions != nil {
+		desc := getClientDescription(info.Agent, info.ClientHints)
+		if q.Browser != "" && !strings.EqualFold(q.Browser, desc.Browser) {
+			return false
+		}
+		if q.OS != "" && !strings.EqualFold(q.OS, desc.OS) {
+			return false
+		}
+		if q.Versions != nil && !q.Versions.Matches(desc) {
+			return false
+		}
+	}
+	return true
+}
+
+//OpenStore opens (creating if need be) the store of the given kind in dataDir
+func OpenStore(kind, dataDir string) (Store, error) {
+	switch kind {
+	case JSONLinesStoreKind:
+		return NewJSONLinesStore(path.Join(dataDir, "browser-data.json")), nil
+	case BoltStoreKind:
+		return NewBoltStore(path.Join(dataDir, "browser-data.db"))
+	}
+	return nil, fmt.Errorf("Unknown store kind %s, expects %s or %s", kind, JSONLinesStoreKind, BoltStoreKind)
+}
+
+func queryStore(ctx context.Context, s Store, q Query) (out []TLSInfoAndAgent, err error) {
+	err = s.Iterate(ctx, ReadOptions{}, func(info TLSInfoAndAgent) error {
+		if q.Matches(info) {
+			out = append(out, info)
+		}
+		return nil
+	})
+	return
+}
+
+//JSONLinesStore keeps records as JSON lines in a file, the format written by the service since its first release
+type JSONLinesStore struct {
+	path    string
+	mutex   sync.Mutex
+	out     *os.File
+	written int64 //the size of the file when it was opened for appending or the last Append completed
+}
+
+//NewJSONLinesStore creates a store backed by the JSON lines file at path. The file is created on the first Append
+func NewJSONLinesStore(path string) *JSONLinesStore {
+	return &JSONLinesStore{path: path}
+}
+
+//Append adds a record to the end of the file
+func (s *JSONLinesStore) Append(info TLSInfoAndAgent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.out == nil {
+		out, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		stat, err := out.Stat()
+		if err != nil {
+			out.Close()
+			return err
+		}
+		s.out, s.written = out, stat.Size()
+	}
+	if err := json.NewEncoder(s.out).Encode(info); err != nil {
+		return err
+	}
+	if err := s.out.Sync(); err != nil {
+		return err
+	}
+	stat, err := s.out.Stat()
+	if err != nil {
+		return err
+	}
+	s.written = stat.Size()
+	return nil
+}
+
+//Iterate reads the file from the start, streaming each record to fn. A file yet to be created holds no records.
+//Once the store has appended to the file, reading stops where the last completed Append ended, so that a record
+//being appended meanwhile is not read half written
+func (s *JSONLinesStore) Iterate(ctx context.Context, opts ReadOptions, fn func(TLSInfoAndAgent) error) error {
+	s.mutex.Lock()
+	appending, written := s.out != nil, s.written
+	s.mutex.Unlock()
+	in, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer in.Close()
+	var r io.Reader = in
+	if appending {
+		r = io.LimitReader(in, written)
+	}
+	return ReadRecords(ctx, r, opts, fn)
+}
+
+//Query returns the records matching q
+func (s *JSONLinesStore) Query(ctx context.Context, q Query) ([]TLSInfoAndAgent, error) {
+	return queryStore(ctx, s, q)
+}
+
+//Count returns the number of records in the file
+func (s *JSONLinesStore) Count(ctx context.Context) (count int, err error) {
+	err = s.Iterate(ctx, ReadOptions{SkipMalformed: true}, func(TLSInfoAndAgent) error {
+		count++
+		return nil
+	})
+	return
+}
+
+//Close closes the file
+func (s *JSONLinesStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.out == nil {
+		return nil
+	}
+	err := s.out.Close()
+	s.out = nil
+	return err
+}
+
+//BoltStore keeps records in an embedded bbolt database, keyed by their sequence number
+type BoltStore struct {
+	db *bolt.DB
+}
+
+//NewBoltStore opens, creating if need be, the bbolt database at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(recordsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+//Append adds a record under the next sequence number
+func (s *BoltStore) Append(info TLSInfoAndAgent) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(recordsBucket)
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return b.Put(key, data)
+	})
+}
+
+//Iterate streams the records to fn in sequence order
+func (s *BoltStore) Iterate(ctx context.Context, opts ReadOptions, fn func(TLSInfoAndAgent) error) error {
+	r := recordReader{ctx: ctx, opts: opts}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(recordsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if done, err := r.next(int(binary.BigEndian.Uint64(k)), 0, v, fn); done {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return r.err()
+}
+
+//Query returns the records matching q
+func (s *BoltStore) Query(ctx context.Context, q Query) ([]TLSInfoAndAgent, error) {
+	return queryStore(ctx, s, q)
+}
+
+//Count returns the number of records in the database
+func (s *BoltStore) Count(ctx context.Context) (count int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(recordsBucket).Stats().KeyN
+		return nil
+	})
+	return
+}
+
+//Close closes the database
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
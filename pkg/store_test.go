@@ -0,0 +1,129 @@
+package model
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+	for _, kind := range []string{JSONLinesStoreKind, BoltStoreKind} {
+		t.Run(kind, func(t *testing.T) {
+			s, err := OpenStore(kind, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			testStore(t, s)
+		})
+	}
+}
+
+func testStore(t *testing.T, s Store) {
+	start := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	agents := []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:67.0) Gecko/20100101 Firefox/67.0",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.100 Safari/537.36",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:60.0) Gecko/20100101 Firefox/60.0",
+	}
+	request := &HTTPRequestInfo{Method: "GET", Proto: "HTTP/1.1", HeaderNames: []string{"Host", "User-Agent", "Accept"}, WireOrder: true}
+	ja4h := TLSInfoAndAgent{HTTPRequest: request}.Fingerprints().JA4H
+	for i, agent := range agents {
+		info := TLSInfoAndAgent{
+			Timestamp: start.Add(time.Duration(i) * time.Hour),
+			Agent:     agent,
+			HelloInfo: &tls.ClientHelloInfo{CipherSuites: []uint16{0x1301, uint16(i)}},
+		}
+		if i == 0 {
+			info.HTTPRequest = request
+		}
+		if err := s.Append(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count, err := s.Count(context.Background()); err != nil || count != 3 {
+		t.Errorf("Expected 3 records, got %d %v", count, err)
+	}
+
+	i := 0
+	err := s.Iterate(context.Background(), ReadOptions{}, func(info TLSInfoAndAgent) error {
+		if info.Agent != agents[i] || !info.Timestamp.Equal(start.Add(time.Duration(i)*time.Hour)) || info.HelloInfo.CipherSuites[1] != uint16(i) {
+			t.Errorf("Record %d was not stored faithfully: %#v", i, info)
+		}
+		i++
+		return nil
+	})
+	if err != nil || i != 3 {
+		t.Errorf("Expected to iterate over 3 records, got %d %v", i, err)
+	}
+
+	firefox65, err := ParseVersionRange("Firefox >= 65")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for q, want := range map[Query]int{
+		{}:                                3,
+		{Browser: "firefox"}:              2,
+		{Browser: "Firefox", OS: "Linux"}: 1,
+		{OS: "Windows 10"}:                2,
+		{Since: start.Add(time.Hour)}:     2,
+		{Until: start.Add(time.Hour)}:     1,
+		{Browser: "IE"}:                   0,
+		{Versions: &firefox65}:            1,
+		{Fingerprint: ja4h}:               1,
+		{Fingerprint: strings.SplitN(ja4h, "_", 2)[0]}: 1,
+		{Fingerprint: "t13d1516h2"}:                    0,
+	} {
+		if out, err := s.Query(context.Background(), q); err != nil || len(out) != want {
+			t.Errorf("Query %#v: expected %d records, got %d %v", q, want, len(out), err)
+		}
+	}
+}
+
+func TestJSONLinesStoreReadsExistingData(t *testing.T) {
+	s := NewJSONLinesStore("../browser-data.json")
+	defer s.Close()
+	count, err := s.Count(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count < 2000 {
+		t.Errorf("Expected the recorded data set, got %d records", count)
+	}
+}
+
+func TestJSONLinesStoreSkipsRecordBeingAppended(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "browser-data.json")
+	s := NewJSONLinesStore(file)
+	defer s.Close()
+	if err := s.Append(TLSInfoAndAgent{Agent: "curl/7.64.1", HelloInfo: &tls.ClientHelloInfo{}}); err != nil {
+		t.Fatal(err)
+	}
+	//the start of a record whose Append is still writing
+	out, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.WriteString(`{"Agent": "Mozilla/5.0`)
+	out.Close()
+
+	records := 0
+	err = s.Iterate(context.Background(), ReadOptions{}, func(TLSInfoAndAgent) error {
+		records++
+		return nil
+	})
+	if err != nil || records != 1 {
+		t.Errorf("Expects the completed record only, got %d %v", records, err)
+	}
+}
+
+func TestOpenUnknownStore(t *testing.T) {
+	if _, err := OpenStore("csv", t.TempDir()); err == nil {
+		t.Errorf("Expected an error for an unknown store kind")
+	}
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"sort"
+)
+
+//VersionGap is a run of recorded browser versions that do not offer a feature offered by older and newer versions
+type VersionGap struct {
+	From string //the first version of the gap
+	To   string //the last version of the gap, the same as From for a single version
+}
+
+//FeatureTimeline is when a browser offered a version, cipher suite, curve, point format, signature scheme or application protocol
+type FeatureTimeline struct {
+	Feature string //one of the Feature kinds
+	Code    string //the hex code point, empty for application protocols
+	Value   string //the name of the feature
+	First   string //the oldest recorded version offering the feature
+	Last    string //the newest recorded version offering the feature
+	Dropped bool   //the newest recorded version of the browser no longer offers the feature
+	Gaps    []VersionGap
+}
+
+//Timeline is the adoption of TLS features by a browser on an operating system family, over its recorded versions
+type Timeline struct {
+	Browser  string
+	OS       string   //the operating system family, or the operating system if its family is unknown
+	Records  int      //the records of the browser and operating system family
+	Versions []string //the recorded versions, the oldest first
+	Features []FeatureTimeline
+}
+
+//BuildTimelines computes, for each browser and operating system family, the first and last recorded version offering
+//each feature, and the recorded versions in between that do not. A version offers a feature if any of its records does.
+//Versions are compared as versions, e.g. 60.8.0esr is 60.8, and records without a recognised browser and version are left out.
+//Timelines are sorted by browser and operating system, and their features by kind, first version and code point
+func BuildTimelines(caps []TLSClientCapability) (out []Timeline) {
+	type versionFeatures struct {
+		version  Version
+		name     string
+		features map[string]bool
+	}
+	type group struct {
+		timeline Timeline
+		versions []*versionFeatures
+		features map[string]feature
+	}
+	groups := map[[2]string]*group{}
+	for _, c := range caps {
+		d := c.ClientDescription
+		v, err := ParseBrowserVersion(d.Browser, d.BrowserVersion)
+		if d.Browser == "" || err != nil {
+			continue
+		}
+		os := d.OSFamily
+		if os == "" {
+			os = d.OS
+		}
+		g, present := groups[[2]string{d.Browser, os}]
+		if !present {
+			g = &group{timeline: Timeline{Browser: d.Browser, OS: os}, features: map[string]feature{}}
+			groups[[2]string{d.Browser, os}] = g
+		}
+		g.timeline.Records++
+		var vf *versionFeatures
+		for _, x := range g.versions {
+			if x.version.Compare(v) == 0 {
+				vf = x
+				break
+			}
+		}
+		if vf == nil {
+			vf = &versionFeatures{version: v, name: d.BrowserVersion, features: map[string]bool{}}
+			g.versions = append(g.versions, vf)
+		}
+		for _, f := range features(c.Capability) {
+			vf.features[f.column()] = true
+			g.features[f.column()] = f
+		}
+	}
+
+	kinds := map[string]int{}
+	for i, k := range featureKinds {
+		kinds[k] = i
+	}
+	for _, g := range groups {
+		sort.Slice(g.versions, func(i, j int) bool { return g.versions[i].version.Compare(g.versions[j].version) < 0 })
+		for _, v := range g.versions {
+			g.timeline.Versions = append(g.timeline.Versions, v.name)
+		}
+		first := map[string]int{}
+		for column, f := range g.features {
+			ft := FeatureTimeline{Feature: f.kind, Code: f.code, Value: f.value}
+			firstIndex, lastIndex := -1, -1
+			for i, v := range g.versions {
+				if v.features[column] {
+					if firstIndex < 0 {
+						firstIndex = i
+					}
+					lastIndex = i
+				}
+			}
+			ft.First, ft.Last = g.versions[firstIndex].name, g.versions[lastIndex].name
+			ft.Dropped = lastIndex < len(g.versions)-1
+			for i := firstIndex + 1; i < lastIndex; i++ {
+				if g.versions[i].features[column] {
+					continue
+				}
+				if n := len(ft.Gaps); n > 0 && ft.Gaps[n-1].To == g.versions[i-1].name {
+					ft.Gaps[n-1].To = g.versions[i].name
+				} else {
+					ft.Gaps = append(ft.Gaps, VersionGap{From: g.versions[i].name, To: g.versions[i].name})
+				}
+			}
+			first[f.column()] = firstIndex
+			g.timeline.Features = append(g.timeline.Features, ft)
+		}
+		fs := g.timeline.Features
+		sort.Slice(fs, func(i, j int) bool {
+			a, b := fs[i], fs[j]
+			if a.Feature != b.Feature {
+				return kinds[a.Feature] < kinds[b.Feature]
+			}
+			fa, fb := first[feature{a.Feature, a.Code, a.Value}.column()], first[feature{b.Feature, b.Code, b.Value}.column()]
+			if fa != fb {
+				return fa < fb
+			}
+			if a.Code != b.Code {
+				return a.Code < b.Code
+			}
+			return a.Value < b.Value
+		})
+		out = append(out, g.timeline)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Browser != out[j].Browser {
+			return out[i].Browser < out[j].Browser
+		}
+		return out[i].OS < out[j].OS
+	})
+	return
+}
+
+//Feature finds the timeline of a feature by its kind and name, e.g. FeatureCurve and x25519
+func (t Timeline) Feature(kind, value string) (FeatureTimeline, bool) {
+	for _, f := range t.Features {
+		if f.Feature == kind && f.Value == value {
+			return f, true
+		}
+	}
+	return FeatureTimeline{}, false
+}
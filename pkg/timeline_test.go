@@ -0,0 +1,61 @@
+package model
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestBuildTimelines(t *testing.T) {
+	client := func(version string, ciphers ...uint16) TLSClientCapability {
+		return TLSClientCapability{
+			ClientDescription: ClientDescription{Browser: "Firefox", BrowserVersion: version, OS: "Windows 10", OSFamily: "Windows"},
+			Capability:        TLSCapability{ClientHelloInfo: tls.ClientHelloInfo{CipherSuites: ciphers, SupportedVersions: []uint16{tls.VersionTLS12}}},
+		}
+	}
+	caps := []TLSClientCapability{
+		client("12.0", 0xc02f),
+		client("9.0", 0xc02f, 0x000a),
+		client("10.0", 0x000a),
+		client("11.0", 0xc02f),
+		client("10.0", 0x000a, 0x0a0a),
+		client("11.0esr", 0xc02f),
+		{ClientDescription: ClientDescription{Browser: "Firefox", BrowserVersion: "latest", OSFamily: "Windows"}},
+		{ClientDescription: ClientDescription{Browser: "Chrome", BrowserVersion: "75.0", OS: "Linux"}},
+	}
+	timelines := BuildTimelines(caps)
+	if len(timelines) != 2 || timelines[0].Browser != "Chrome" || timelines[0].OS != "Linux" {
+		t.Fatalf("Expects a timeline for Chrome on Linux and Firefox on Windows, got %+v", timelines)
+	}
+	firefox := timelines[1]
+	if firefox.Records != 6 || len(firefox.Versions) != 4 || firefox.Versions[0] != "9.0" || firefox.Versions[3] != "12.0" {
+		t.Errorf("Unexpected versions %v of %d records", firefox.Versions, firefox.Records)
+	}
+	if len(firefox.Features) != 3 || firefox.Features[0].Feature != FeatureVersion {
+		t.Fatalf("Expects the TLS 1.2, two cipher suite features without GREASE, got %+v", firefox.Features)
+	}
+
+	gcm, ok := firefox.Feature(FeatureCipherSuite, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	if !ok || gcm.First != "9.0" || gcm.Last != "12.0" || gcm.Dropped || len(gcm.Gaps) != 1 || gcm.Gaps[0] != (VersionGap{"10.0", "10.0"}) {
+		t.Errorf("Unexpected timeline %+v", gcm)
+	}
+	tripleDES, ok := firefox.Feature(FeatureCipherSuite, "TLS_RSA_WITH_3DES_EDE_CBC_SHA")
+	if !ok || tripleDES.First != "9.0" || tripleDES.Last != "10.0" || !tripleDES.Dropped || len(tripleDES.Gaps) != 0 {
+		t.Errorf("Unexpected timeline %+v", tripleDES)
+	}
+}
+
+func TestBuildTimelinesBrowserData(t *testing.T) {
+	data, err := GetEnrichedData("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tl := range BuildTimelines(data) {
+		if tl.Browser == "Firefox" && tl.OS == "Windows" {
+			if f, ok := tl.Feature(FeatureVersion, "TLS v1.3"); !ok || f.First != "63.0" {
+				t.Errorf("Expects Firefox to offer TLS 1.3 from version 63, got %+v", f)
+			}
+			return
+		}
+	}
+	t.Error("Expects a timeline of Firefox on Windows")
+}
@@ -1,65 +1,89 @@
 package model
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/json"
-	"io"
-	"log"
-	"os"
-	"path"
+	"strings"
 
 	tlsdefs "github.com/adedayo/tls-definitions"
 )
 
-//GetEnrichedData retrieves browser TLS audit data with further enrichment and annotations
-func GetEnrichedData(dataDir string) (out []TLSClientCapability) {
-	data := GetRawData(dataDir)
-	for _, d := range data {
-		out = append(out, TLSClientCapability{
-			ClientDescription: getClientDescription(d.Agent),
-			Agent:             d.Agent,
-			Capability:        getTLSCapability(d.HelloInfo),
-		})
-	}
+//GetEnrichedData retrieves browser TLS audit data with further enrichment and annotations.
+//Malformed records are skipped and reported in a *MalformedRecordsError alongside the rest of the data
+func GetEnrichedData(dataDir string) (out []TLSClientCapability, err error) {
+	err = IterateEnrichedData(context.Background(), dataDir, ReadOptions{SkipMalformed: true}, func(c TLSClientCapability) error {
+		out = append(out, c)
+		return nil
+	})
+	return
+}
+
+//GetRawData retrieves browser TLS audit data. A *DataNotFoundError is returned if there is no data in dataDir.
+//Malformed records are skipped and reported in a *MalformedRecordsError alongside the rest of the data. Use IterateRawData to stream large data sets
+func GetRawData(dataDir string) (out []TLSInfoAndAgent, err error) {
+	err = IterateRawData(context.Background(), dataDir, ReadOptions{SkipMalformed: true}, func(info TLSInfoAndAgent) error {
+		out = append(out, info)
+		return nil
+	})
 	return
 }
 
-//GetRawData retrieves browser TLS audit data
-func GetRawData(dataDir string) (out []TLSInfoAndAgent) {
-	in, err := os.OpenFile(path.Join(dataDir, "browser-data.json"), os.O_RDONLY, 0644)
-	if err != nil {
-		log.Fatal(err)
-		return
+//Enrich describes the client of a captured record from its user agent and Client Hints, fingerprints it and grades its TLS capability
+func Enrich(d TLSInfoAndAgent) TLSClientCapability {
+	cap := getTLSCapability(d.HelloInfo)
+	return TLSClientCapability{
+		ClientDescription: getClientDescription(d.Agent, d.ClientHints),
+		Agent:             d.Agent,
+		Capability:        cap,
+		ClientHello:       d.ClientHello,
+		Fingerprints:      d.Fingerprints(),
+		Assessment:        Assess(cap),
 	}
-	defer in.Close()
-	dec := json.NewDecoder(in)
-	for {
-		if len(out) > 5000 { //return no more than 5000 records
-			break
+}
+
+//ParseClientDescription recognises the browser, its version, operating system, engine, device class and architecture from a user agent.
+//An *UnknownAgentError is returned if either cannot be recognised
+func ParseClientDescription(ua string) (ClientDescription, error) {
+	return currentAgentRules().describe(ua)
+}
+
+//getClientDescription recognises the client from its user agent, preferring the browser, version and operating system given by any Client Hints
+func getClientDescription(ua string, hints *ClientHints) ClientDescription {
+	desc, _ := ParseClientDescription(ua)
+	if hints != nil {
+		//the low entropy hints that arrive without asking have the significant version of the browser and the platform
+		//without its version, which the user agent may describe better
+		if name, version, ok := hints.Browser(); ok {
+			desc.Browser = name
+			if hints.FullVersionList != "" || majorVersion(desc.BrowserVersion) != majorVersion(version) {
+				desc.BrowserVersion = version
+			}
+			if chromium := hints.ChromiumVersion(); hints.FullVersionList != "" || majorVersion(desc.ChromiumVersion) != majorVersion(chromium) {
+				desc.ChromiumVersion = chromium
+			}
 		}
-		info := TLSInfoAndAgent{}
-		if err := dec.Decode(&info); err == io.EOF {
-			break
-		} else if err == nil {
-			out = append(out, info)
-		} else {
-			println(err.Error())
+		if os, ok := hints.OS(); ok && (unquoteStructured(hints.PlatformVersion) != "" || !strings.HasPrefix(desc.OS, os)) {
+			desc.OS = os
+			desc.OSFamily, desc.OSVersion = hints.OSVersion()
+		}
+		if arch := hints.Architecture(); arch != "" {
+			desc.Architecture = arch
+		}
+		if device := hints.DeviceClass(); device != "" && !desc.Bot {
+			desc.DeviceClass = device
 		}
 	}
-
-	return
+	return desc
 }
 
-func getClientDescription(ua string) (desc ClientDescription) {
-	if br, err := getBrowserVersionAndOS(ua); err == nil {
-		desc.Browser = br.name
-		desc.BrowserVersion = br.version
-		desc.OS = br.os
-	}
-	return
+func majorVersion(version string) string {
+	return strings.SplitN(version, ".", 2)[0]
 }
 
 func getTLSCapability(h *tls.ClientHelloInfo) TLSCapability {
+	if h == nil {
+		return TLSCapability{}
+	}
 	cap := TLSCapability{
 		ClientHelloInfo: *h,
 	}
@@ -0,0 +1,344 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	versionPattern    = regexp.MustCompile(`^(\d+(?:[.]\d+)*)[.-]?([A-Za-z][0-9A-Za-z.-]*)?$`)
+	constraintPattern = regexp.MustCompile(`^\s*(>=|<=|!=|==|=|<|>)?\s*(\d[0-9A-Za-z.-]*)`)
+	rangeNameEnd      = regexp.MustCompile(`[<>=!]|(?:^|\s)\d`)
+	//preReleases are the suffixes of versions that come before their release, e.g. Firefox 68.0b3 before 68.0
+	preReleases = []string{"a", "alpha", "b", "beta", "pre", "rc", "dev"}
+)
+
+//Version is a browser or operating system version: its numeric components, compared numerically, and any suffix,
+//e.g. 76.0.3809.100 for Chrome, 68.0b3 for Firefox or 12.1 for Safari
+type Version struct {
+	Parts  []int  //the numeric components, most significant first
+	Suffix string //what follows the numbers, e.g. esr or b3
+}
+
+//ParseVersion reads a dotted version with an optional suffix, e.g. 12.1, 76.0.3809.100 or 68.0b3
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Version{}, fmt.Errorf("Invalid version %q", s)
+	}
+	v := Version{Suffix: strings.ToLower(m[2])}
+	for _, p := range strings.Split(m[1], ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return Version{}, fmt.Errorf("Invalid version %q: %s", s, err.Error())
+		}
+		v.Parts = append(v.Parts, n)
+	}
+	return v, nil
+}
+
+//ParseBrowserVersion reads a version in the scheme of a browser. Versions compare component by component, e.g. Chrome's
+//major.minor.build.patch. Safari is described by the marketing version of its Version/ token, e.g. 12.1, and only agents
+//without one by their WebKit build, whose platform digit prefixed on mobile, e.g. the 7 of 7534.48.3, is dropped so builds
+//order across platforms. Firefox's esr suffix marks an extended support build of a release, not a pre-release, so 60.8.0esr
+//is dropped to 60.8.0 and sorts with its release line. Opera's Presto versions, up to 12, write their minor version as
+//hundredths, so 11.5 is read as 11.50 and follows 11.10, and all order below its Blink versions, from 15
+func ParseBrowserVersion(browser, s string) (Version, error) {
+	v, err := ParseVersion(s)
+	if err != nil {
+		return v, err
+	}
+	switch browser {
+	case "Safari":
+		if v.Parts[0] >= 1000 {
+			v.Parts[0] %= 1000
+		}
+	case "Firefox":
+		if v.Suffix == "esr" {
+			v.Suffix = ""
+		}
+	case "Opera":
+		if parts := strings.Split(strings.TrimSpace(s), "."); v.Parts[0] <= 12 && len(parts) == 2 && len(parts[1]) == 1 {
+			v.Parts[1] *= 10
+		}
+	}
+	return v, nil
+}
+
+//Compare returns -1, 0 or 1 as v is older than, the same as or newer than o. Missing components count as 0, so 12.1 is 12.1.0,
+//and pre-releases such as 68.0b3 are older than their release
+func (v Version) Compare(o Version) int {
+	for i := 0; i < len(v.Parts) || i < len(o.Parts); i++ {
+		a, b := v.part(i), o.part(i)
+		if a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	pa, pb := v.isPreRelease(), o.isPreRelease()
+	switch {
+	case pa && !pb:
+		return -1
+	case pb && !pa:
+		return 1
+	case pa && pb:
+		return compareSuffixes(v.Suffix, o.Suffix)
+	}
+	return 0
+}
+
+//compareSuffixes orders pre-release suffixes by their label and then their number, so b3 comes before b10
+func compareSuffixes(a, b string) int {
+	la, lb := strings.TrimRight(a, "0123456789.-"), strings.TrimRight(b, "0123456789.-")
+	if la != lb {
+		return strings.Compare(la, lb)
+	}
+	na, _ := strconv.Atoi(strings.Trim(a[len(la):], ".-"))
+	nb, _ := strconv.Atoi(strings.Trim(b[len(lb):], ".-"))
+	switch {
+	case na < nb:
+		return -1
+	case na > nb:
+		return 1
+	}
+	return 0
+}
+
+func (v Version) part(i int) int {
+	if i < len(v.Parts) {
+		return v.Parts[i]
+	}
+	return 0
+}
+
+func (v Version) isPreRelease() bool {
+	for _, p := range preReleases {
+		if strings.HasPrefix(v.Suffix, p) && strings.TrimLeft(v.Suffix[len(p):], "0123456789.-") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+//truncate keeps the n most significant components, dropping the suffix if any are lost
+func (v Version) truncate(n int) Version {
+	if len(v.Parts) <= n {
+		return v
+	}
+	return Version{Parts: v.Parts[:n]}
+}
+
+func (v Version) String() string {
+	parts := make([]string, len(v.Parts))
+	for i, p := range v.Parts {
+		parts[i] = strconv.Itoa(p)
+	}
+	return strings.Join(parts, ".") + v.Suffix
+}
+
+//compareVersions orders two versions of a browser, falling back to comparing them as text when either does not parse
+func compareVersions(browser, a, b string) int {
+	va, errA := ParseBrowserVersion(browser, a)
+	vb, errB := ParseBrowserVersion(browser, b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return va.Compare(vb)
+}
+
+//versionSpan is the oldest and newest of the versions seen of a browser
+type versionSpan struct {
+	min, max *Version
+}
+
+//add widens the span to a version of browser, ignoring versions that do not parse
+func (s *versionSpan) add(browser, version string) {
+	if v, err := ParseBrowserVersion(browser, version); err == nil {
+		s.merge(versionSpan{&v, &v})
+	}
+}
+
+func (s *versionSpan) merge(o versionSpan) {
+	if o.min != nil && (s.min == nil || o.min.Compare(*s.min) < 0) {
+		s.min = o.min
+	}
+	if o.max != nil && (s.max == nil || o.max.Compare(*s.max) > 0) {
+		s.max = o.max
+	}
+}
+
+//selector selects the versions of the span, e.g. Chrome >= 70 <= 75, or the browser alone if no version was seen
+func (s versionSpan) selector(browser string) VersionSelector {
+	sel := VersionSelector{Name: browser}
+	switch {
+	case s.min == nil:
+	case s.min.Compare(*s.max) == 0:
+		sel.Constraints = []VersionConstraint{{Op: "=", Version: *s.min}}
+	default:
+		sel.Constraints = []VersionConstraint{{Op: ">=", Version: *s.min}, {Op: "<=", Version: *s.max}}
+	}
+	return sel
+}
+
+//VersionConstraint bounds a version, e.g. >= 70
+type VersionConstraint struct {
+	Op      string //one of =, !=, <, <=, > or >=
+	Version Version
+}
+
+//Allows reports whether a version meets the constraint. The version is compared to the precision of the constraint,
+//so 75.0.3770.100 is <= 75 and = 75
+func (c VersionConstraint) Allows(v Version) bool {
+	cmp := v.truncate(len(c.Version.Parts)).Compare(c.Version)
+	switch c.Op {
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+func (c VersionConstraint) String() string {
+	return c.Op + " " + c.Version.String()
+}
+
+//VersionSelector selects a browser or operating system family and the versions meeting all its constraints
+type VersionSelector struct {
+	Name        string //a browser, e.g. Chrome, or an operating system family, e.g. Windows. Empty for the versions of any browser
+	Constraints []VersionConstraint
+}
+
+//Matches reports whether a client is selected. The name is matched against the browser and then the operating system family,
+//ignoring case, and the constraints against the corresponding version
+func (s VersionSelector) Matches(d ClientDescription) bool {
+	var v Version
+	var err error
+	switch {
+	case s.Name == "" || strings.EqualFold(s.Name, d.Browser):
+		v, err = ParseBrowserVersion(d.Browser, d.BrowserVersion)
+	case strings.EqualFold(s.Name, d.OSFamily):
+		v, err = ParseVersion(d.OSVersion)
+	default:
+		return false
+	}
+	if len(s.Constraints) == 0 {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	for _, c := range s.Constraints {
+		if !c.Allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s VersionSelector) String() string {
+	parts := []string{}
+	if s.Name != "" {
+		parts = append(parts, s.Name)
+	}
+	for _, c := range s.Constraints {
+		parts = append(parts, c.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+//VersionRange selects clients by browser or operating system and version. The zero value selects every client
+type VersionRange struct {
+	Selectors []VersionSelector //a client is selected by any of them
+}
+
+//ParseVersionRange reads a comma separated list of selectors, each a browser or operating system family followed by constraints
+//on its version, e.g. "Chrome >= 70 < 76, Firefox 68, Safari, Windows >= 10". A version without an operator selects that version
+//to its precision, so Firefox 68 is any 68.x
+func ParseVersionRange(expr string) (VersionRange, error) {
+	r := VersionRange{}
+	if strings.TrimSpace(expr) == "" {
+		return r, nil
+	}
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.TrimSpace(item)
+		s := VersionSelector{Name: item}
+		if loc := rangeNameEnd.FindStringIndex(item); loc != nil {
+			s.Name = strings.TrimSpace(item[:loc[0]])
+			rest := item[loc[0]:]
+			for strings.TrimSpace(rest) != "" {
+				m := constraintPattern.FindStringSubmatchIndex(rest)
+				if m == nil {
+					return r, fmt.Errorf("Invalid version range %q, expects constraints such as >= 70 after %q", item, s.Name)
+				}
+				op := "="
+				if m[2] >= 0 && rest[m[2]:m[3]] != "==" {
+					op = rest[m[2]:m[3]]
+				}
+				v, err := ParseVersion(rest[m[4]:m[5]])
+				if err != nil {
+					return r, err
+				}
+				s.Constraints = append(s.Constraints, VersionConstraint{Op: op, Version: v})
+				rest = rest[m[1]:]
+			}
+		}
+		if s.Name == "" && len(s.Constraints) == 0 {
+			return r, fmt.Errorf("Invalid version range %q, expects a browser or operating system in each selector", expr)
+		}
+		r.Selectors = append(r.Selectors, s)
+	}
+	return r, nil
+}
+
+//Matches reports whether any of the selectors matches the client, always true for the zero range
+func (r VersionRange) Matches(d ClientDescription) bool {
+	if len(r.Selectors) == 0 {
+		return true
+	}
+	for _, s := range r.Selectors {
+		if s.Matches(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r VersionRange) String() string {
+	parts := make([]string, len(r.Selectors))
+	for i, s := range r.Selectors {
+		parts[i] = s.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+//MarshalText writes the range as an expression, see ParseVersionRange
+func (r VersionRange) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+//UnmarshalText reads the range from an expression, see ParseVersionRange
+func (r *VersionRange) UnmarshalText(text []byte) (err error) {
+	*r, err = ParseVersionRange(string(text))
+	return
+}
+
+//FilterByVersions selects the client capabilities in the version range
+func FilterByVersions(data []TLSClientCapability, r VersionRange) (out []TLSClientCapability) {
+	for _, d := range data {
+		if r.Matches(d.ClientDescription) {
+			out = append(out, d)
+		}
+	}
+	return
+}
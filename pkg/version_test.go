@@ -0,0 +1,151 @@
+package model
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestParseBrowserVersion(t *testing.T) {
+	for _, c := range []struct {
+		browser, a, b string
+		want          int
+	}{
+		{"Chrome", "9.0", "10.0", -1},
+		{"Chrome", "76.0.3809.100", "76.0.3809.87", 1},
+		{"Firefox", "60.8.0esr", "60.8", 0},
+		{"Firefox", "68.0b3", "68.0", -1},
+		{"Firefox", "68.0b3", "68.0b10", -1},
+		{"Safari", "7534.48.3", "604.1", -1},
+		{"Firefox", "60.8.0esr", "60.9", -1},
+		{"Firefox", "68.0.1esr", "68.0b3", 1},
+		{"Opera", "12", "62.0.3331.72", -1},
+		{"Opera", "12.16", "15.0.1147.100", -1},
+		{"Opera", "11.5", "11.50", 0},
+		{"Opera", "11.5", "11.10", 1},
+		{"Opera", "9.64", "12.15", -1},
+		{"Safari", "12.1", "12.1.0", 0},
+	} {
+		a, err := ParseBrowserVersion(c.browser, c.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseBrowserVersion(c.browser, c.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != c.want {
+			t.Errorf("Expects %s %s compared to %s to be %d, got %d", c.browser, c.a, c.b, c.want, got)
+		}
+	}
+	if v, err := ParseVersion("60.8.0esr"); err != nil || v.String() != "60.8.0esr" {
+		t.Errorf("Unexpected version %v %v", v, err)
+	}
+	if v, err := ParseBrowserVersion("Firefox", "60.8.0esr"); err != nil || v.String() != "60.8.0" {
+		t.Errorf("Expects the esr suffix to be dropped, got %v %v", v, err)
+	}
+	if _, err := ParseVersion("latest"); err == nil {
+		t.Error("Expects an error for a version without numbers")
+	}
+}
+
+func TestVersionRange(t *testing.T) {
+	r, err := ParseVersionRange("Chrome >= 70 <76, firefox 68, Windows>=10, Safari")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != "Chrome >= 70 < 76, firefox = 68, Windows >= 10, Safari" {
+		t.Errorf("Unexpected range %s", r)
+	}
+	for d, want := range map[ClientDescription]bool{
+		{Browser: "Chrome", BrowserVersion: "70.0.3538.77"}:                           true,
+		{Browser: "Chrome", BrowserVersion: "75.0.3770.100"}:                          true,
+		{Browser: "Chrome", BrowserVersion: "76.0.3809.100"}:                          false,
+		{Browser: "Chrome", BrowserVersion: "9.0"}:                                    false,
+		{Browser: "Firefox", BrowserVersion: "68.0.2"}:                                true,
+		{Browser: "Firefox", BrowserVersion: "67.0"}:                                  false,
+		{Browser: "Safari", BrowserVersion: "604.1"}:                                  true,
+		{Browser: "IE", BrowserVersion: "11.0", OSFamily: "Windows", OSVersion: "10"}: true,
+		{Browser: "IE", BrowserVersion: "8.0", OSFamily: "Windows", OSVersion: "5.1"}: false,
+	} {
+		if r.Matches(d) != want {
+			t.Errorf("Expects %s to match %+v: %t", r, d, want)
+		}
+	}
+	if all, err := ParseVersionRange(""); err != nil || !all.Matches(ClientDescription{Browser: "Chrome"}) {
+		t.Errorf("Expects the empty range to match every client %v", err)
+	}
+	for _, bad := range []string{"Chrome >= seventy", ">=", "Chrome, "} {
+		if _, err := ParseVersionRange(bad); err == nil {
+			t.Errorf("Expects an error for %q", bad)
+		}
+	}
+}
+
+func TestClientDescriptionVersionOrder(t *testing.T) {
+	descs := []ClientDescription{
+		{Browser: "Chrome", BrowserVersion: "10.0"},
+		{Browser: "Chrome", BrowserVersion: "9.0"},
+		{Browser: "Chrome", BrowserVersion: "9.0", OS: "Mac OS X (Yosemite)", OSFamily: "macOS", OSVersion: "10.10"},
+		{Browser: "Chrome", BrowserVersion: "9.0", OS: "Mac OS X (Mavericks)", OSFamily: "macOS", OSVersion: "10.9"},
+	}
+	sort.Slice(descs, func(i, j int) bool { return descs[i].less(descs[j]) })
+	if descs[0].OS != "" || descs[1].OSVersion != "10.9" || descs[2].OSVersion != "10.10" || descs[3].BrowserVersion != "10.0" {
+		t.Errorf("Unexpected order %+v", descs)
+	}
+}
+
+func TestSafariVersion(t *testing.T) {
+	r, err := ParseVersionRange("Safari >= 13")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for agent, want := range map[string]bool{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1.1 Safari/605.1.15":                   false,
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 13_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.4 Mobile/15E148 Safari/604.1": true,
+	} {
+		d, err := ParseClientDescription(agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Matches(d) != want {
+			t.Errorf("Expects %s to match Safari %s: %t", r, d.BrowserVersion, want)
+		}
+	}
+}
+
+func TestOperaVersion(t *testing.T) {
+	agents := []string{
+		"Opera/9.64 (Windows NT 5.1; U; en) Presto/2.1.1",
+		"Opera/9.80 (Windows NT 6.1; U; en) Presto/2.8.131 Version/11.10",
+		"Opera/9.80 (Windows NT 6.1; U; en) Presto/2.9.168 Version/11.50",
+		"Opera/9.80 (Windows NT 6.1; WOW64) Presto/2.12.388 Version/12.16",
+		"Mozilla/5.0 (Windows NT 6.1; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/28.0.1500.52 Safari/537.36 OPR/15.0.1147.100",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_14_5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/75.0.3770.100 Safari/537.36 OPR/62.0.3331.72",
+	}
+	versions := []string{"9.64", "11.10", "11.50", "12.16", "15.0.1147.100", "62.0.3331.72"}
+	var previous Version
+	for i, agent := range agents {
+		d, err := ParseClientDescription(agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Browser != "Opera" || d.BrowserVersion != versions[i] {
+			t.Errorf("Expects Opera %s, got %s %s", versions[i], d.Browser, d.BrowserVersion)
+		}
+		v, err := ParseBrowserVersion(d.Browser, d.BrowserVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && previous.Compare(v) >= 0 {
+			t.Errorf("Expects Opera %s before %s", previous, v)
+		}
+		previous = v
+	}
+	r, err := ParseVersionRange("Opera < 15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Matches(ClientDescription{Browser: "Opera", BrowserVersion: "12.16"}) || r.Matches(ClientDescription{Browser: "Opera", BrowserVersion: "15.0.1147.100"}) {
+		t.Errorf("Expects %s to select the Presto versions only", r)
+	}
+}